@@ -9,11 +9,11 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"time"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/clip/pkg/nflag"
-	"github.com/kballard/go-shellquote"
 )
 
 // cmdClone is the static clone command.
@@ -24,20 +24,17 @@ var cmdClone = &clip.LeafCommand[environ]{
 
 // cmdCloneRunner runs the clone command.
 type cmdCloneRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
 	// Repo is the repository to clone.
 	Repo string
 
-	// Style is the nil-safe lipgloss style to use.
-	Style *nilSafeLipglossStyle
-
 	// VWriterStderr is the writer executed to log the executed commands stderr.
 	VWriterStderr io.Writer
 
 	// VWriterStdout is the writer executed to log the executed commands stdout.
 	VWriterStdout io.Writer
-
-	// XWriter is the writer used to log executed commands.
-	XWriter io.Writer
 }
 
 // --- entry & setup ---
@@ -51,11 +48,10 @@ func cmdCloneMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
 func mustNewCmdCloneRunner(args *clip.CommandArgs[environ]) *cmdCloneRunner {
 	// Initialize the default configuration.
 	c := &cmdCloneRunner{
+		LockTimeout:   -1,
 		Repo:          "",
-		Style:         nil,
 		VWriterStderr: io.Discard,
 		VWriterStdout: io.Discard,
-		XWriter:       io.Discard,
 	}
 
 	// Create empty command line parser.
@@ -74,9 +70,30 @@ func mustNewCmdCloneRunner(args *clip.CommandArgs[environ]) *cmdCloneRunner {
 	// Add the `-x` flag.
 	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
 
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
 	// Parse the command line arguments.
 	assert.NotError(fset.Parse(args.Args))
 
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
 	// Set the repository name to clone.
 	c.Repo = fset.Args()[0]
 
@@ -86,12 +103,6 @@ func mustNewCmdCloneRunner(args *clip.CommandArgs[environ]) *cmdCloneRunner {
 		c.VWriterStdout = args.Env.Stdout()
 	}
 
-	// Honour the `-x` flag.
-	if *xflag {
-		c.XWriter = args.Env.Stderr()
-		c.Style = newNilSafeLipglossStyle()
-	}
-
 	return c
 }
 
@@ -99,8 +110,8 @@ func mustNewCmdCloneRunner(args *clip.CommandArgs[environ]) *cmdCloneRunner {
 
 func (c *cmdCloneRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
 	// Lock the multirepo dir
-	dd := defaultDotDir()
-	unlock, err := dd.lock(args.Env)
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
 	if err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo clone: %s\n", err)
 		return err
@@ -123,6 +134,7 @@ func (c *cmdCloneRunner) clone(ctx context.Context, env environ, dd dotDir) erro
 	if err != nil {
 		return err
 	}
+	before := config.Clone()
 
 	// Parses the scp-like URL.
 	scpInfo, good := scpLikeParse(c.Repo)
@@ -131,13 +143,13 @@ func (c *cmdCloneRunner) clone(ctx context.Context, env environ, dd dotDir) erro
 	}
 
 	// Create the subcommand to execute.
-	cmd := exec.CommandContext(ctx, "git", "clone", scpInfo.String(), scpInfo.Name())
+	cmd := exec.CommandContext(ctx, "git", "clone", scpInfo.String(), dd.repoPath(scpInfo.Name()))
 	cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
 	cmd.Stdout = c.VWriterStdout
 	cmd.Stderr = c.VWriterStderr
 
-	// Log that we're executing the command.
-	mustFprintf(c.XWriter, "%s\n", c.Style.Renderf("+ %s", shellquote.Join(cmd.Args...)))
+	// Give git the chance to remove the partial clone when interrupted
+	procConfigureGracefulCancel(cmd, procGroupKillGrace)
 
 	// Execute the command
 	if err := env.RunCommand(cmd); err != nil {
@@ -146,7 +158,7 @@ func (c *cmdCloneRunner) clone(ctx context.Context, env environ, dd dotDir) erro
 
 	// Update the configuration file.
 	config.AddRepo(scpInfo.Name(), scpInfo.String())
-	if err := config.WriteFile(env, dd.configFilePath()); err != nil {
+	if err := dd.saveConfig(ctx, env, journalArgv(env), before, config); err != nil {
 		return err
 	}
 
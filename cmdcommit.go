@@ -0,0 +1,244 @@
+// cmdcommit.go - implementation of the commit command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// commitChangeIDTrailer is the trailer linking the commits created together.
+const commitChangeIDTrailer = "Multirepo-Change-Id"
+
+// cmdCommit is the static commit command
+var cmdCommit = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Commit the changes of all the repositories with a shared message.",
+	RunFunc:              cmdCommitMain,
+}
+
+// cmdCommitRunner runs the commit command.
+type cmdCommitRunner struct {
+	// All indicates whether to also commit the modified tracked files that are not staged.
+	All bool
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Message is the message template, where `{repo}` expands to the repository name.
+	Message string
+}
+
+// --- entry & setup ---
+
+// cmdCommitMain is the entry point for the commit command.
+func cmdCommitMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdCommitRunner(args).run(ctx, args)
+}
+
+// mustNewCmdCommitRunner creates a new [*cmdCommitRunner].
+func mustNewCmdCommitRunner(args *clip.CommandArgs[environ]) *cmdCommitRunner {
+	// Initialize the default configuration.
+	c := &cmdCommitRunner{
+		All:         false,
+		LockTimeout: -1,
+		Message:     "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 0
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-a` flag.
+	fset.BoolVar(&c.All, "all", 'a', "Also commit the modified tracked files that are not staged.")
+
+	// Add the `-m` flag.
+	fset.StringVar(&c.Message, "message", 'm', "Use the given message, where {repo} expands to the repository name.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Make sure we have a message.
+	if strings.TrimSpace(c.Message) == "" {
+		mustFprintf(args.Env.Stderr(), "%s: missing -m flag\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	return c
+}
+
+// --- execution ---
+
+// commitRepo is a repository in which we create a commit.
+type commitRepo struct {
+	// Name is the repository name.
+	Name string
+
+	// Index is the tree object recording the index before committing,
+	// which differs from the committed tree when committing with `-a`.
+	Index string
+
+	// Parent is the commit checked out before committing (empty when
+	// the repository did not have any commit yet).
+	Parent string
+}
+
+func (c *cmdCommitRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Read the repositories to commit
+	dd := defaultDotDir(args.Env)
+	config, err := readConfigSnapshot(args.Env, dd, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo commit: %s\n", err)
+		return err
+	}
+
+	// Select the repositories containing changes to commit
+	repos := []commitRepo{}
+	for _, name := range config.SelectedRepos() {
+		changed, err := c.hasChanges(ctx, args.Env, dd, name)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo commit: %s\n", err)
+			return err
+		}
+		if !changed {
+			continue
+		}
+		index, err := dd.queryRepoGit(ctx, args.Env, name, "write-tree")
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo commit: %s\n", err)
+			return err
+		}
+		parent, _ := dd.queryRepoGit(ctx, args.Env, name, "rev-parse", "-q", "--verify", "HEAD")
+		repos = append(repos, commitRepo{Name: name, Index: index, Parent: parent})
+	}
+	if len(repos) <= 0 {
+		err := errors.New("nothing to commit")
+		mustFprintf(args.Env.Stderr(), "multirepo commit: %s\n", err)
+		return err
+	}
+
+	// Commit in each repository and, when a commit fails (e.g., because of
+	// a failing hook), roll back the commits we have already created
+	changeID := newCommitChangeID()
+	for idx, repo := range repos {
+		if err := c.commit(ctx, args.Env, dd, repo.Name, changeID); err != nil {
+			err = fmt.Errorf("%s: %w", repo.Name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo commit: %s\n", err)
+			errlist := []error{err}
+			for _, done := range repos[:idx] {
+				if err := c.rollback(ctx, args.Env, dd, done); err != nil {
+					err = fmt.Errorf("%s: cannot roll back: %w", done.Name, err)
+					mustFprintf(args.Env.Stderr(), "multirepo commit: %s\n", err)
+					errlist = append(errlist, err)
+					continue
+				}
+				mustFprintf(args.Env.Stderr(), "multirepo commit: %s: rolled back\n", done.Name)
+			}
+			return errors.Join(errlist...)
+		}
+	}
+
+	// Print the created commits and the change ID linking them
+	for _, repo := range repos {
+		head, _ := dd.queryRepoGit(ctx, args.Env, repo.Name, "rev-parse", "--short", "HEAD")
+		mustFprintf(args.Env.Stdout(), "%s %s\n", repo.Name, head)
+	}
+	mustFprintf(args.Env.Stdout(), "%s: %s\n", commitChangeIDTrailer, changeID)
+	return nil
+}
+
+// hasChanges returns whether the given repository contains staged changes
+// or, when committing all the changes, modified tracked files.
+func (c *cmdCommitRunner) hasChanges(ctx context.Context, env environ, dd dotDir, repo string) (bool, error) {
+	argvs := [][]string{{"diff", "--cached", "--quiet"}}
+	if c.All {
+		argvs = append(argvs, []string{"diff", "--quiet"})
+	}
+	for _, argv := range argvs {
+		_, err := dd.queryRepoGit(ctx, env, repo, argv...)
+		if commandExitCode(err) == 1 {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// commit commits in the given repository adding the change ID trailer.
+func (c *cmdCommitRunner) commit(ctx context.Context, env environ, dd dotDir, repo, changeID string) error {
+	argv := []string{"commit", "-q"}
+	if c.All {
+		argv = append(argv, "-a")
+	}
+	argv = append(argv,
+		"-m", strings.ReplaceAll(c.Message, "{repo}", repo),
+		"--trailer", commitChangeIDTrailer+": "+changeID,
+	)
+	return dd.runRepoGit(ctx, env, repo, argv...)
+}
+
+// rollback removes the commit we created in the given repository (i.e., `git
+// reset --soft`) and restores the index as it was before committing, such that
+// the changes staged by `-a` are no longer staged.
+func (c *cmdCommitRunner) rollback(ctx context.Context, env environ, dd dotDir, repo commitRepo) error {
+	argv := []string{"reset", "-q", "--soft", repo.Parent}
+	if repo.Parent == "" {
+		// Without a parent, we restore the unborn branch
+		argv = []string{"update-ref", "-d", "HEAD"}
+	}
+	if err := dd.runRepoGit(ctx, env, repo.Name, argv...); err != nil {
+		return err
+	}
+	return dd.runRepoGit(ctx, env, repo.Name, "read-tree", repo.Index)
+}
+
+// newCommitChangeID generates a new random change ID.
+func newCommitChangeID() string {
+	var data [20]byte
+	_, err := rand.Read(data[:])
+	assert.NotError(err)
+	return hex.EncodeToString(data[:])
+}
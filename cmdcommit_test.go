@@ -0,0 +1,56 @@
+// cmdcommit_test.go - Tests for the 'commit' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitRollback(t *testing.T) {
+	root := newTestMultirepo(t, "r1", "r2")
+	for _, name := range []string{"r1", "r2"} {
+		dir := filepath.Join(root, name)
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte("hello\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		testGit(t, dir, "add", "README")
+		testCommit(t, dir, "add README")
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte("changed\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stage a new file in r1, which should still be staged after the rollback,
+	// and make committing in r2 fail using a hook
+	r1 := filepath.Join(root, "r1")
+	if err := os.WriteFile(filepath.Join(r1, "NEWS"), []byte("news\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, r1, "add", "NEWS")
+	hook := filepath.Join(root, "r2", ".git", "hooks", "pre-commit")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	before := testGit(t, r1, "rev-parse", "HEAD")
+
+	_, stderr, err := testRunCommand(cmdCommit, "commit", "-a", "-m", "change {repo}")
+	if err == nil {
+		t.Fatal("expected commit to fail")
+	}
+	if !strings.Contains(stderr, "r1: rolled back") {
+		t.Fatalf("unexpected stderr: %q", stderr)
+	}
+	if after := testGit(t, r1, "rev-parse", "HEAD"); after != before {
+		t.Fatalf("expected r1 to be at %s, got %s", before, after)
+	}
+	if staged := testGit(t, r1, "diff", "--cached", "--name-only"); staged != "NEWS" {
+		t.Fatalf("expected only NEWS to be staged, got %q", staged)
+	}
+	if modified := testGit(t, r1, "diff", "--name-only"); modified != "README" {
+		t.Fatalf("expected README to be modified but not staged, got %q", modified)
+	}
+}
@@ -0,0 +1,136 @@
+// cmdconfigshow.go - implementation of the 'config show' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdConfigShow is the static 'config show' command
+var cmdConfigShow = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Show the effective multirepo configuration.",
+	RunFunc:              cmdConfigShowMain,
+}
+
+// cmdConfigShowRunner runs the 'config show' command.
+type cmdConfigShowRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Origin indicates whether to show where each value comes from.
+	Origin bool
+}
+
+// --- entry & setup ---
+
+// cmdConfigShowMain is the entry point for the 'config show' command.
+func cmdConfigShowMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdConfigShowRunner(args).run(args)
+}
+
+// mustNewCmdConfigShowRunner creates a new [*cmdConfigShowRunner].
+func mustNewCmdConfigShowRunner(args *clip.CommandArgs[environ]) *cmdConfigShowRunner {
+	// Initialize the default configuration.
+	c := &cmdConfigShowRunner{
+		LockTimeout: -1,
+		Origin:      false,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = ""
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 0
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `--origin` flag.
+	fset.BoolVar(&c.Origin, "origin", 0, "Show the file defining each value.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdConfigShowRunner) run(args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo config show: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Read the configuration file
+	config, err := readConfig(args.Env, dd.configFilePath())
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo config show: %s\n", err)
+		return err
+	}
+
+	// Print the effective configuration as JSON unless we need the origins
+	if !c.Origin {
+		effective := struct {
+			Version  int                 `json:"version"`
+			Repos    map[string]repoInfo `json:"repos"`
+			Settings configSettings      `json:"settings"`
+		}{config.Version, config.Repos, config.Effective}
+		mustFprintf(args.Env.Stdout(), "%s\n", mustMarshalIndentJSON(effective, "", "  "))
+		return nil
+	}
+
+	// Print each value along with its origin
+	tw := tabwriter.NewWriter(args.Env.Stdout(), 0, 8, 2, ' ', 0)
+	mustFprintf(tw, "version\t%d\t%s\n", config.Version, config.Origins["version"])
+	for _, name := range slices.Sorted(maps.Keys(config.Repos)) {
+		mustFprintf(tw, "repos.%s.url\t%s\t%s\n", name, config.Repos[name].URL, config.Origins["repos"])
+	}
+	for _, key := range configSettingsKeys {
+		mustFprintf(tw, "settings.%s\t%s\t%s\n", key, c.settingValue(&config.Effective, key), config.Origins["settings."+key])
+	}
+	return tw.Flush()
+}
+
+// settingValue returns the JSON representation of the given setting.
+func (c *cmdConfigShowRunner) settingValue(settings *configSettings, key string) string {
+	var raw map[string]json.RawMessage
+	assert.NotError(json.Unmarshal(mustMarshalJSON(settings), &raw))
+	value, found := raw[key]
+	assert.True(found, fmt.Sprintf("unknown setting: %s", key))
+	return string(value)
+}
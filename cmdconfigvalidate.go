@@ -0,0 +1,150 @@
+// cmdconfigvalidate.go - implementation of the 'config validate' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdConfigValidate is the static 'config validate' command
+var cmdConfigValidate = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Validate the multirepo configuration.",
+	RunFunc:              cmdConfigValidateMain,
+}
+
+// cmdConfigValidateRunner runs the 'config validate' command.
+type cmdConfigValidateRunner struct {
+	// Filename is the optional configuration file to validate.
+	Filename string
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdConfigValidateMain is the entry point for the 'config validate' command.
+func cmdConfigValidateMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdConfigValidateRunner(args).run(args)
+}
+
+// mustNewCmdConfigValidateRunner creates a new [*cmdConfigValidateRunner].
+func mustNewCmdConfigValidateRunner(args *clip.CommandArgs[environ]) *cmdConfigValidateRunner {
+	// Initialize the default configuration.
+	c := &cmdConfigValidateRunner{
+		Filename:    "",
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "[file]"
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Set the file to validate.
+	if len(fset.Args()) > 0 {
+		c.Filename = fset.Args()[0]
+	}
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdConfigValidateRunner) run(args *clip.CommandArgs[environ]) error {
+	// Handle the case where we validate an arbitrary file
+	if c.Filename != "" {
+		return c.report(args.Env, c.Filename, c.validate(args.Env, c.Filename))
+	}
+
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo config validate: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Validate the shared configuration and the existing overlays
+	errlist := []error{}
+	if err := c.report(args.Env, dd.configFilePath(), c.validate(args.Env, dd.configFilePath())); err != nil {
+		errlist = append(errlist, err)
+	}
+	for _, overlayPath := range configOverlayPaths(args.Env, dd.configFilePath()) {
+		overlay, err := readConfigOverlay(args.Env, overlayPath)
+		if overlay == nil && err == nil {
+			continue // the overlay does not exist
+		}
+		if err := c.report(args.Env, overlayPath, err); err != nil {
+			errlist = append(errlist, err)
+		}
+	}
+	return errors.Join(errlist...)
+}
+
+// report prints the result of validating the given file.
+func (c *cmdConfigValidateRunner) report(env environ, filename string, err error) error {
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			mustFprintf(env.Stderr(), "multirepo config validate: %s\n", line)
+		}
+		return err
+	}
+	mustFprintf(env.Stdout(), "%s: valid\n", filename)
+	return nil
+}
+
+// validate validates the configuration without rewriting the file, even when
+// the configuration would need to be migrated to the current version.
+func (c *cmdConfigValidateRunner) validate(env environ, filename string) error {
+	// Read the file from the disk
+	data, err := env.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	// Migrate in memory from older versions
+	migrated, _, err := migrateConfig(filename, data)
+	if err != nil {
+		return checkConfigSyntax(filename, data, err)
+	}
+
+	// Parse and validate the configuration
+	_, err = parseConfig(filename, migrated, data)
+	return err
+}
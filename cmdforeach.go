@@ -10,12 +10,19 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/clip/pkg/nflag"
-	"github.com/kballard/go-shellquote"
 )
 
 // cmdForeach is the static foreach command
@@ -29,12 +36,47 @@ type cmdForeachRunner struct {
 	// Argv contains the command and its arguments.
 	Argv []string
 
+	// Interactive indicates whether to attach the terminal to the commands
+	// and ask the user whether to execute in each repository.
+	Interactive bool
+
 	// KeepGoing indicates whether to continue executing commands even if one fails.
 	KeepGoing bool
 
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Pager is the command line of the pager through which we pipe the
+	// combined output of the commands (empty means no paging).
+	Pager []string
+
+	// Predicates contains the conditions for executing in a repository.
+	Predicates foreachPredicates
+
+	// Report is the format of the report to write (empty means no report).
+	Report string
+
+	// ReportFile is the file where to write the report.
+	ReportFile string
+
+	// RerunFailed indicates whether to only execute the command in the
+	// repositories where the previous invocation failed.
+	RerunFailed bool
+
+	// Snippet is the shell snippet to execute (see the `-c` flag).
+	Snippet string
+
 	// Style is the nil-safe lipgloss style to use.
 	Style *nilSafeLipglossStyle
 
+	// Timeout is the timeout for executing the command in each
+	// repository (zero means no timeout).
+	Timeout time.Duration
+
+	// TotalTimeout is the timeout for executing the command in all
+	// the repositories (zero means no timeout).
+	TotalTimeout time.Duration
+
 	// XWriter is the writer used to log executed commands.
 	XWriter io.Writer
 }
@@ -50,72 +92,296 @@ func cmdForeachMain(ctx context.Context, args *clip.CommandArgs[environ]) error
 func mustNewCmdForeachRunner(args *clip.CommandArgs[environ]) *cmdForeachRunner {
 	// Initialize the default configuration.
 	c := &cmdForeachRunner{
-		Argv:      []string{},
-		KeepGoing: false,
-		Style:     nil,
-		XWriter:   io.Discard,
+		Argv:         []string{},
+		Interactive:  false,
+		KeepGoing:    false,
+		LockTimeout:  -1,
+		Pager:        []string{},
+		Predicates:   foreachPredicates{},
+		Report:       "",
+		ReportFile:   "",
+		RerunFailed:  false,
+		Snippet:      "",
+		Style:        nil,
+		Timeout:      0,
+		TotalTimeout: 0,
+		XWriter:      io.Discard,
 	}
 
 	// Create empty command line parser.
 	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
 	fset.Description = args.Command.BriefDescription()
-	fset.PositionalArgumentsUsage = "<command> [args...]"
+	fset.PositionalArgumentsUsage = "<command> [args...] | -c <snippet>"
 	fset.DisablePermute = true // Disable option permutaion to allow passing options to subcommands
-	fset.MinPositionalArgs = 1
+	fset.MinPositionalArgs = 0
 	fset.MaxPositionalArgs = math.MaxInt
 
 	// Add the `-h, --help` flag.
 	fset.AutoHelp("help", 'h', "Show this help message and exit.")
 
+	// Add the `-c` flag.
+	fset.StringVar(&c.Snippet, "command", 'c', "Execute the given shell snippet using $SHELL (or sh).")
+
+	// Add the `-i` flag.
+	fset.BoolVar(&c.Interactive, "interactive", 'i', "Attach the terminal and ask before executing in each repository.")
+
 	// Add the `-k` flag.
 	kflag := fset.Bool("keep-going", 'k', "Continue iterating even if the subcommand fails.")
 
+	// Add the `--if` and `--if-*` flags.
+	c.Predicates.AddFlags(fset)
+
+	// Add the `-p` flag.
+	pflag := fset.Bool("paginate", 'p', "Pipe the combined output through $MULTIREPO_PAGER or $PAGER.")
+
+	// Add the `--report` and `--report-file` flags.
+	fset.StringVar(&c.Report, "report", 0, "Write a report using the given format (json, junit, or tap).")
+	fset.StringVar(&c.ReportFile, "report-file", 0, "Write the report to the given file.")
+
+	// Add the `--rerun-failed` flag.
+	fset.BoolVar(&c.RerunFailed, "rerun-failed", 0, "Only execute in the repositories where the previous invocation failed.")
+
+	// Add the `--timeout` and `--total-timeout` flags.
+	timeout := fset.String("timeout", 0, "Stop the command in a repository after the given duration (e.g., 5m).")
+	totalTimeout := fset.String("total-timeout", 0, "Stop executing commands after the given duration (e.g., 1h).")
+
 	// Add the `-x` flag.
 	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
 
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
 	// Parse the command line arguments.
 	assert.NotError(fset.Parse(args.Args))
 
-	// Add the command to execute.
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+	if *xflag {
+		c.XWriter = args.Env.Stderr()
+		c.Style = newNilSafeLipglossStyle()
+	}
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the command to execute, which defaults to the
+	// previous one when using the `--rerun-failed` flag.
 	c.Argv = fset.Args()
+	switch {
+	case c.Snippet != "" && len(c.Argv) > 0:
+		mustFprintf(args.Env.Stderr(), "%s: -c does not accept positional arguments\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	case c.Snippet != "":
+		c.Argv = []string{c.shell(args.Env), "-c", c.Snippet}
+	case len(c.Argv) <= 0 && !c.RerunFailed:
+		mustFprintf(args.Env.Stderr(), "%s: expected at least one positional argument\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
 
 	// Honour the `-k` flag.
 	if *kflag {
 		c.KeepGoing = true
 	}
 
-	// Honour the `-x` flag.
-	if *xflag {
-		c.XWriter = args.Env.Stderr()
-		c.Style = newNilSafeLipglossStyle()
+	// Honour the `--report` and `--report-file` flags.
+	if c.ReportFile != "" && c.Report == "" {
+		c.Report = "json"
+	}
+	if c.Report != "" && (c.ReportFile == "" || !slices.Contains(foreachReportFormats, c.Report)) {
+		mustFprintf(args.Env.Stderr(), "%s: --report requires --report-file and one of: %s\n",
+			args.CommandName, strings.Join(foreachReportFormats, ", "))
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	// Honour the `-i` flag, which is incompatible with capturing the output.
+	if c.Interactive && c.Report != "" {
+		mustFprintf(args.Env.Stderr(), "%s: -i cannot be used along with --report\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	// Honour the `-p` flag, which only makes sense when stdout is a terminal
+	// and is incompatible with attaching the terminal to the commands.
+	if *pflag && c.Interactive {
+		mustFprintf(args.Env.Stderr(), "%s: -p cannot be used along with -i\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	if *pflag && isTerminal(args.Env.Stdout()) {
+		pager, err := foreachPagerCommand(args.Env)
+		if err != nil {
+			mustFprintf(args.Env.Stderr(), "%s: %s\n", args.CommandName, err)
+			args.Env.Exit(2)
+		}
+		c.Pager = pager
+	}
+
+	// Honour the `--timeout` and `--total-timeout` flags, which require running
+	// each command in its own process group, while `-i` keeps the commands in
+	// our process group such that they can read from the terminal.
+	c.Timeout = c.mustParseTimeout(args, "--timeout", *timeout)
+	c.TotalTimeout = c.mustParseTimeout(args, "--total-timeout", *totalTimeout)
+	if c.Interactive && (c.Timeout > 0 || c.TotalTimeout > 0) {
+		mustFprintf(args.Env.Stderr(), "%s: -i cannot be used along with --timeout or --total-timeout\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
 	}
 
 	return c
 }
 
+// shell returns the shell to use for executing snippets, i.e.,
+// the value of the `SHELL` environment variable or `sh`.
+func (c *cmdForeachRunner) shell(env environ) string {
+	if shell, found := env.LookupEnv("SHELL"); found && shell != "" {
+		return shell
+	}
+	return "sh"
+}
+
+// mustParseTimeout parses the value of a timeout flag, where the empty string
+// means no timeout. Like [nflag.ExitOnError], we print an error and exit on
+// invalid values.
+func (c *cmdForeachRunner) mustParseTimeout(args *clip.CommandArgs[environ], flag, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		mustFprintf(args.Env.Stderr(), "%s: invalid %s value: %q\n", args.CommandName, flag, value)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	return timeout
+}
+
 // --- execution ---
 
+// errForeachTimeout indicates that the command timed out in a repository.
+var errForeachTimeout = errors.New("timed out")
+
+// errForeachInterrupted indicates that the user interrupted the execution.
+var errForeachInterrupted = errors.New("interrupted")
+
+// foreachResult is the result of executing the command in a repository.
+type foreachResult struct {
+	// Repo is the repository name.
+	Repo string
+
+	// Duration is the time it took to execute the command.
+	Duration time.Duration
+
+	// Err is the error that occurred, if any.
+	Err error
+
+	// SkipReason explains why we did not execute the command because
+	// the repository did not satisfy the predicates, if that is the case.
+	SkipReason string
+
+	// Stdout contains the standard output captured for the report.
+	Stdout []byte
+
+	// Stderr contains the standard error captured for the report.
+	Stderr []byte
+}
+
+// Status returns "passed", "skipped", "failed", "timeout", or "interrupted"
+// depending on the result.
+func (r *foreachResult) Status() string {
+	switch {
+	case r.Err == nil && r.SkipReason != "":
+		return "skipped"
+	case r.Err == nil:
+		return "passed"
+	case errors.Is(r.Err, errForeachTimeout):
+		return "timeout"
+	case errors.Is(r.Err, errForeachInterrupted):
+		return "interrupted"
+	default:
+		return "failed"
+	}
+}
+
 func (c *cmdForeachRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
-	// Lock the multirepo dir
-	dd := defaultDotDir()
-	unlock, err := dd.lock(args.Env)
+	// Snapshot the repositories to iterate over
+	dd := defaultDotDir(args.Env)
+	repos, infos, err := c.snapshot(args.Env, dd)
 	if err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo foreach: %s\n", err)
 		return err
 	}
-	defer unlock()
 
-	// Read the configuration file
-	config, err := readConfig(args.Env, dd.configFilePath())
-	if err != nil {
-		mustFprintf(args.Env.Stderr(), "multirepo foreach: %s\n", err)
-		return err
+	// Tell the user when there is nothing to rerun
+	if c.RerunFailed && len(repos) <= 0 {
+		mustFprintf(args.Env.Stderr(), "multirepo foreach: no failed repositories to rerun\n")
+	}
+
+	// Handle interrupts, such that the first one stops scheduling
+	// repositories and the second one terminates the running command
+	ctx, interrupted, stopWatching := c.watchInterrupts(ctx, args.Env)
+	defer stopWatching()
+
+	// Bound the whole execution using the total timeout, if any
+	if c.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.TotalTimeout)
+		defer cancel()
 	}
 
-	// Execute command in each repository
+	// Execute command in each repository not excluded by the settings
+	pager := &foreachPager{}
+	start := time.Now()
+	results := []foreachResult{}
 	errlist := []error{}
-	for repo := range config.Repos {
-		if err := c.execute(ctx, args.Env, repo); err != nil {
+	skipped := 0
+	aborted := false
+	for idx, repo := range repos {
+		// Stop scheduling repositories once interrupted or the context is done
+		if interrupted.Load() || ctx.Err() != nil {
+			break
+		}
+
+		// Collect the output when paging and capture it when we need to write a report
+		var stdout, stderr bytes.Buffer
+		output := &foreachOutput{}
+		stdoutw, stderrw := args.Env.Stdout(), args.Env.Stderr()
+		if len(c.Pager) > 0 {
+			stdoutw, stderrw = output, output
+		}
+		if c.Report != "" {
+			stdoutw, stderrw = io.MultiWriter(stdoutw, &stdout), io.MultiWriter(stderrw, &stderr)
+		}
+
+		target := foreachTarget{Name: repo, Info: infos[repo], Index: idx, Count: len(repos)}
+		result := c.visit(ctx, args.Env, dd, target, interrupted, stdoutw, stderrw)
+		if errors.Is(result.Err, errForeachAborted) {
+			aborted = true
+			break
+		}
+		result.Stdout, result.Stderr = stdout.Bytes(), stderr.Bytes()
+		results = append(results, result)
+		if result.SkipReason != "" {
+			skipped++
+		} else if len(c.Pager) > 0 {
+			pager.Add(target, output.Bytes())
+		}
+		if err := result.Err; err != nil {
+			err = fmt.Errorf("%s: %w", repo, err)
 			mustFprintf(args.Env.Stderr(), "multirepo foreach: %s\n", err)
 			errlist = append(errlist, err)
 			if !c.KeepGoing {
@@ -124,61 +390,424 @@ func (c *cmdForeachRunner) run(ctx context.Context, args *clip.CommandArgs[envir
 		}
 	}
 
+	// Page the combined output, if needed, after we stopped watching
+	// interrupts, such that the pager (e.g., less) handles them.
+	stopWatching()
+	if len(c.Pager) > 0 {
+		if err := pager.Run(context.WithoutCancel(ctx), args.Env, c.Pager); err != nil {
+			mustFprintf(args.Env.Stderr(), "multirepo foreach: %s\n", err)
+			errlist = append(errlist, err)
+		}
+	}
+
+	// Consider the whole execution failed if interrupted or aborted
+	switch {
+	case interrupted.Load():
+		errlist = append(errlist, errForeachInterrupted)
+	case aborted:
+		errlist = append(errlist, errForeachAborted)
+	}
+
+	// Summarize the failures, if any, which repositories completed, if
+	// we have been interrupted or aborted, and which ones we skipped, if any
+	if len(errlist) > 0 || skipped > 0 {
+		c.summarize(args.Env, results, len(repos))
+	}
+
+	// Write the report, if needed
+	if c.Report != "" {
+		report := &foreachReport{
+			Argv:     c.Argv,
+			Start:    start,
+			Duration: time.Since(start),
+			Results:  results,
+			Skipped:  repos[len(results):],
+		}
+		if err := args.Env.WriteFile(c.ReportFile, report.Marshal(c.Report), 0644); err != nil {
+			mustFprintf(args.Env.Stderr(), "multirepo foreach: %s\n", err)
+			errlist = append(errlist, err)
+		}
+	}
+
+	// Remember where we failed for `--rerun-failed`
+	if err := c.saveFailed(args.Env, dd, repos, results); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo foreach: %s\n", err)
+		errlist = append(errlist, err)
+	}
+
 	return errors.Join(errlist...)
 }
 
-// execute executes the command in a given repository.
-func (c *cmdForeachRunner) execute(ctx context.Context, env environ, repo string) error {
+// visit executes the command in the given repository using the given stdout and
+// stderr, provided that the repository satisfies the predicates and, in interactive
+// mode, that the user does not skip it. Otherwise, the result contains the reason
+// why we skipped the repository. When the user aborts, the result contains
+// [errForeachAborted] and we do not execute the command.
+func (c *cmdForeachRunner) visit(ctx context.Context, env environ, dd dotDir,
+	target foreachTarget, interrupted *atomic.Bool, stdout, stderr io.Writer) foreachResult {
+	result := foreachResult{Repo: target.Name}
+
+	// Create the environment for the commands
+	environ, path, err := c.environ(env, dd, target)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// Skip the repository unless it satisfies the predicates
+	skip, err := c.Predicates.Evaluate(ctx, env, dd, target.Name, environ, c.shell(env))
+
+	// In interactive mode, ask the user whether to skip the repository
+	if err == nil && skip == "" && c.Interactive {
+		skip, err = c.prompt(ctx, env, interrupted, target)
+	}
+	if err != nil || skip != "" {
+		if skip != "" {
+			mustFprintf(c.XWriter, "%s\n", c.Style.Renderf("+ # skipping %s: %s", target.Name, skip))
+		}
+		result.Err, result.SkipReason = err, skip
+		return result
+	}
+
+	// Execute the command measuring how long it takes
+	t0 := time.Now()
+	result.Err = c.executeWithTimeout(ctx, env, dd, target, environ, path, stdout, stderr)
+	result.Duration = time.Since(t0)
+	return result
+}
+
+// executeWithTimeout is like [cmdForeachRunner.execute] but bounds the execution
+// using the per-repository timeout and reports timeouts using [errForeachTimeout]
+// and the commands terminated because of interrupts using [errForeachInterrupted].
+func (c *cmdForeachRunner) executeWithTimeout(ctx context.Context, env environ, dd dotDir,
+	target foreachTarget, environ []string, path string, stdout, stderr io.Writer) error {
+	// Create the per-repository context
+	repoCtx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		repoCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	// Execute and distinguish interrupts and timeouts from failures
+	err := c.execute(repoCtx, env, dd, target, environ, path, stdout, stderr)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(repoCtx.Err(), context.Canceled):
+		return errForeachInterrupted
+	case !errors.Is(repoCtx.Err(), context.DeadlineExceeded):
+		return err
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: the total timeout of %s expired", errForeachTimeout, c.TotalTimeout)
+	default:
+		return fmt.Errorf("%w after %s", errForeachTimeout, c.Timeout)
+	}
+}
+
+// snapshot returns the names of the repositories to iterate over and the
+// information about all the repositories. We only hold the lock while
+// reading the configuration, such that we do not block other multirepo invocations
+// while the subcommands run and such that subcommands can invoke multirepo. When
+// rerunning the failed repositories, we also default to the previous command.
+func (c *cmdForeachRunner) snapshot(env environ, dd dotDir) ([]string, map[string]repoInfo, error) {
+	// Lock the multirepo dir
+	unlock, err := dd.lock(env, lockShared, c.LockTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	// Read the configuration file
+	config, err := readConfig(env, dd.configFilePath())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !c.RerunFailed {
+		return config.SelectedRepos(), config.Repos, nil
+	}
+
+	// Read the repositories where the previous invocation failed
+	failed, err := readForeachFailed(env, dd.foreachFailedFilePath())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(c.Argv) <= 0 {
+		if len(failed.Argv) <= 0 {
+			return nil, nil, errors.New("no previous invocation to rerun (hint: specify the command to execute)")
+		}
+		c.Argv = failed.Argv
+	}
+
+	// Only keep the failed repositories that are still selected
+	repos := []string{}
+	for _, repo := range config.SelectedRepos() {
+		if slices.Contains(failed.Repos, repo) {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, config.Repos, nil
+}
+
+// watchInterrupts implements two-stage interrupt handling. The returned context
+// does not inherit the cancellation of the given one (which [clip.RootCommand]
+// cancels on interrupt), and the first interrupt only sets the returned flag,
+// such that we stop scheduling repositories while waiting for the running
+// command. The second interrupt cancels the context, thus terminating the
+// process group of the running command (see [procGroupConfigure]), to which
+// the terminal does not deliver interrupts. In interactive mode, instead, the
+// command runs in our process group, thus also receiving interrupts directly,
+// and we only interrupt the command itself. The returned function stops
+// watching, such that we ignore further interrupts, and is idempotent.
+func (c *cmdForeachRunner) watchInterrupts(
+	ctx context.Context, env environ) (context.Context, *atomic.Bool, func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	interrupted := &atomic.Bool{}
+	sigch := make(chan os.Signal, 2)
+	env.SignalNotify(sigch, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigch:
+			}
+			if !interrupted.Swap(true) {
+				mustFprintf(env.Stderr(), "\nmultirepo foreach: interrupted: waiting for the running "+
+					"command to complete (interrupt again to terminate it)\n")
+				continue
+			}
+			mustFprintf(env.Stderr(), "\nmultirepo foreach: interrupted again: terminating the running command\n")
+			cancel()
+		}
+	}()
+	stop := sync.OnceFunc(func() {
+		close(done)
+		cancel()
+	})
+	return ctx, interrupted, stop
+}
+
+// summarize prints a table with the results highlighting the failures.
+func (c *cmdForeachRunner) summarize(env environ, results []foreachResult, total int) {
+	// Format the table
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	mustFprintf(tw, "REPO\tSTATUS\tEXIT\tDURATION\n")
+	for _, result := range results {
+		mustFprintf(tw, "%s\t%s\t%d\t%s\n", result.Repo, result.Status(),
+			commandExitCode(result.Err), result.Duration.Round(time.Millisecond))
+	}
+	assert.NotError(tw.Flush())
+
+	// Print the table highlighting the rows of the failed repositories
+	failureStyle := newNilSafeLipglossFailureStyle()
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	mustFprintf(env.Stderr(), "\n%s\n", lines[0])
+	var failures, timeouts, interrupts, skips int
+	for idx, result := range results {
+		line := lines[idx+1]
+		if result.Err != nil {
+			line = failureStyle.Render(line)
+			failures++
+		}
+		if result.SkipReason != "" {
+			skips++
+		}
+		switch {
+		case errors.Is(result.Err, errForeachTimeout):
+			timeouts++
+		case errors.Is(result.Err, errForeachInterrupted):
+			interrupts++
+		}
+		mustFprintf(env.Stderr(), "%s\n", line)
+	}
+
+	// Print the totals
+	var details []string
+	if timeouts > 0 {
+		details = append(details, fmt.Sprintf("%d timed out", timeouts))
+	}
+	if interrupts > 0 {
+		details = append(details, fmt.Sprintf("%d interrupted", interrupts))
+	}
+	if skips > 0 {
+		details = append(details, fmt.Sprintf("%d skipped", skips))
+	}
+	if skipped := total - len(results); skipped > 0 {
+		details = append(details, fmt.Sprintf("%d not executed", skipped))
+	}
+	if len(details) > 0 {
+		mustFprintf(env.Stderr(), "\nmultirepo foreach: %d of %d repositories failed (%s)\n",
+			failures, total, strings.Join(details, ", "))
+		return
+	}
+	mustFprintf(env.Stderr(), "\nmultirepo foreach: %d of %d repositories failed\n", failures, total)
+}
+
+// saveFailed saves the repositories where the command failed or that we did not
+// reach because we stopped at the first failure, such that `foreach --rerun-failed`
+// can execute the command again therein. To avoid taking the exclusive lock for
+// nothing, we only save when something failed or when rerunning, such that a
+// successful rerun clears the list, and never in nested invocations, which would
+// otherwise overwrite the list of the outer invocation.
+func (c *cmdForeachRunner) saveFailed(env environ, dd dotDir, repos []string, results []foreachResult) error {
+	// Determine the failed repositories
+	failed := &foreachFailed{Argv: c.Argv, Time: time.Now().UTC(), Repos: []string{}}
+	for _, result := range results {
+		if result.Err != nil {
+			failed.Repos = append(failed.Repos, result.Repo)
+		}
+	}
+	failed.Repos = append(failed.Repos, repos[len(results):]...)
+
+	// Check whether we need to save them
+	if _, nested := env.LookupEnv(foreachRootEnv); nested {
+		return nil
+	}
+	if len(failed.Repos) <= 0 && !c.RerunFailed {
+		return nil
+	}
+
+	// Lock the multirepo dir and write the failed repositories
+	unlock, err := dd.lock(env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeForeachFailed(env, dd.foreachFailedFilePath(), failed)
+}
+
+// foreachTarget describes the repository in which we execute the command.
+type foreachTarget struct {
+	// Name is the repository name.
+	Name string
+
+	// Info contains the repository information.
+	Info repoInfo
+
+	// Index is the zero-based index of the repository among the ones we iterate over.
+	Index int
+
+	// Count is the number of repositories we iterate over.
+	Count int
+}
+
+// Environ returns the environment variables describing the repository, whose
+// absolute path is the given one. We derive the host and the owner from the URL
+// and leave them empty when the URL is empty or is a path.
+func (t *foreachTarget) Environ(path string) []string {
+	var host, owner string
+	if epnt, good := scpLikeParseURL(t.Info.URL); good {
+		host, owner = epnt.Host, epnt.Owner()
+	}
+	return []string{
+		fmt.Sprintf("MULTIREPO_REPO_NAME=%s", t.Name),
+		fmt.Sprintf("MULTIREPO_REPO_URL=%s", t.Info.URL),
+		fmt.Sprintf("MULTIREPO_REPO_PATH=%s", path),
+		fmt.Sprintf("MULTIREPO_REPO_INDEX=%d", t.Index),
+		fmt.Sprintf("MULTIREPO_REPO_COUNT=%d", t.Count),
+		fmt.Sprintf("MULTIREPO_REPO_TAGS=%s", strings.Join(t.Info.Tags, ",")),
+		fmt.Sprintf("MULTIREPO_REPO_HOST=%s", host),
+		fmt.Sprintf("MULTIREPO_REPO_OWNER=%s", owner),
+	}
+}
+
+// Expand replaces the `{name}`, `{url}`, and `{path}` placeholders in the given
+// arguments with the repository name, URL, and the given absolute path.
+func (t *foreachTarget) Expand(argv []string, path string) []string {
+	replacer := strings.NewReplacer("{name}", t.Name, "{url}", t.Info.URL, "{path}", path)
+	expanded := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		expanded = append(expanded, replacer.Replace(arg))
+	}
+	return expanded
+}
+
+// environ returns the environment for the commands we execute in the
+// given repository along with the absolute path of the repository.
+func (c *cmdForeachRunner) environ(env environ, dd dotDir, target foreachTarget) ([]string, string, error) {
 	// Preparing for adding to the environment variables.
 	environ := env.Environ()
 
-	// Conditionally add the `MULTIREPO_ROOT` environment variable.
-	if _, found := env.LookupEnv("MULTIREPO_ROOT"); !found {
-		wdir, err := env.Getwd()
-		if err != nil {
-			return err
-		}
-		variable := fmt.Sprintf("MULTIREPO_ROOT=%s", wdir)
-		environ = append(environ, variable)
+	// Add the `MULTIREPO_ROOT` environment variable, for the commands, and the
+	// [foreachRootEnv] one, for nested invocations (see [defaultDotDir]).
+	root, err := env.AbsFilepath(dd.rootDir())
+	if err != nil {
+		return nil, "", err
 	}
+	environ = append(environ, fmt.Sprintf("MULTIREPO_ROOT=%s", root))
+	environ = append(environ, fmt.Sprintf("%s=%s", foreachRootEnv, root))
 
 	// Conditionally add the `MULTIREPO_EXECUTABLE` environment variable.
 	if _, found := env.LookupEnv("MULTIREPO_EXECUTABLE"); !found {
 		exe, err := env.Executable()
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 		exe, err = env.AbsFilepath(exe)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 		variable := fmt.Sprintf("MULTIREPO_EXECUTABLE=%s", exe)
 		environ = append(environ, variable)
 	}
 
+	// Add the environment variables describing the repository, overriding
+	// the ones set by an outer `multirepo foreach` invocation.
+	path, err := env.AbsFilepath(dd.repoPath(target.Name))
+	if err != nil {
+		return nil, "", err
+	}
+	return append(environ, target.Environ(path)...), path, nil
+}
+
+// execute executes the command in a given repository, whose absolute path is the
+// given one, using the given environment, stdout, and stderr.
+func (c *cmdForeachRunner) execute(ctx context.Context, env environ, dd dotDir,
+	target foreachTarget, environ []string, path string, stdout, stderr io.Writer) error {
+	// Expand the placeholders unless we're executing a shell snippet, which
+	// could legitimately contain them (e.g., `${name}`) and can anyway use
+	// the environment variables describing the repository.
+	argv := c.Argv
+	if c.Snippet == "" {
+		argv = target.Expand(argv, path)
+	}
+
 	// As an optimization, if we're running `git`, prepend the
 	// `--no-pager` option otherwise... it's painful!
-	assert.True(len(c.Argv) >= 1, "expected at least the command name")
-	reargv := []string{c.Argv[0]}
+	assert.True(len(argv) >= 1, "expected at least the command name")
+	reargv := []string{argv[0]}
 	if reargv[0] == "git" {
 		reargv = append(reargv, "--no-pager")
+		if len(c.Pager) > 0 {
+			// We page the output ourselves, so preserve the colors
+			reargv = append(reargv, "-c", "color.ui=always")
+		}
 	}
-	reargv = append(reargv, c.Argv[1:]...)
+	reargv = append(reargv, argv[1:]...)
 
 	// Create the subcommand to execute.
 	cmd := exec.CommandContext(ctx, reargv[0], reargv[1:]...)
 	cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
-	cmd.Stdout = env.Stdout()
-	cmd.Stderr = env.Stderr()
-	cmd.Dir = repo
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Dir = dd.repoPath(target.Name)
 	cmd.Env = environ
 
-	// Log that we're executing the command.
-	//
-	// Add a newline before each entry so that it stands out when
-	// skimming the terminal. Note that we cannot make `-x` the
-	// default, since it would be quite annoying when reading diffs
-	mustFprintf(c.XWriter, "%s\n", c.Style.Renderf("+ (cd %s && %s)", shellquote.Join(repo), shellquote.Join(cmd.Args...)))
+	// Run the command in its own process group, such that we terminate the
+	// whole process tree on timeout or interrupt. The only exception is the
+	// interactive mode, where we attach the standard input and run in our
+	// process group, such that the command can read from the terminal (e.g.,
+	// `git add -p`) and the terminal delivers interrupts to it as well.
+	if c.Interactive {
+		cmd.Stdin = env.Stdin()
+		procConfigureGracefulCancel(cmd, procGroupKillGrace)
+	} else {
+		release := procGroupConfigure(cmd, procGroupKillGrace)
+		defer release()
+	}
 
 	// Execute the command
 	return env.RunCommand(cmd)
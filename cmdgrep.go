@@ -0,0 +1,319 @@
+// cmdgrep.go - implementation of the grep command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdGrep is the static grep command
+var cmdGrep = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Search for a pattern in all the repositories.",
+	RunFunc:              cmdGrepMain,
+}
+
+// cmdGrepRunner runs the grep command.
+type cmdGrepRunner struct {
+	// Count indicates whether to show the number of matching lines of each file.
+	Count bool
+
+	// FilesWithMatches indicates whether to only show the names of the matching files.
+	FilesWithMatches bool
+
+	// Jobs is the number of repositories to search in parallel, where
+	// zero means using the `jobs` setting or the number of CPUs.
+	Jobs int64
+
+	// JSON indicates whether to emit JSON.
+	JSON bool
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Pathspecs contains the optional pathspecs limiting the search.
+	Pathspecs []string
+
+	// Pattern is the pattern to search for.
+	Pattern string
+}
+
+// --- entry & setup ---
+
+// cmdGrepMain is the entry point for the grep command.
+func cmdGrepMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdGrepRunner(args).run(ctx, args)
+}
+
+// mustNewCmdGrepRunner creates a new [*cmdGrepRunner].
+func mustNewCmdGrepRunner(args *clip.CommandArgs[environ]) *cmdGrepRunner {
+	// Initialize the default configuration.
+	c := &cmdGrepRunner{
+		Count:            false,
+		FilesWithMatches: false,
+		Jobs:             0,
+		JSON:             false,
+		LockTimeout:      -1,
+		Pathspecs:        []string{},
+		Pattern:          "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<pattern> [-- <pathspec>...]"
+	fset.MinPositionalArgs = 1
+	fset.MaxPositionalArgs = math.MaxInt
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-c` flag.
+	fset.BoolVar(&c.Count, "count", 'c', "Show the number of matching lines of each file.")
+
+	// Add the `-j` flag.
+	fset.Int64Var(&c.Jobs, "jobs", 'j', "Search the given number of repositories in parallel.")
+
+	// Add the `--json` flag.
+	fset.BoolVar(&c.JSON, "json", 0, "Emit the matches as a JSON array.")
+
+	// Add the `-l` flag.
+	fset.BoolVar(&c.FilesWithMatches, "files-with-matches", 'l', "Only show the names of the matching files.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the pattern and the pathspecs.
+	c.Pattern, c.Pathspecs = fset.Args()[0], fset.Args()[1:]
+
+	// Make sure the output format is not ambiguous.
+	if c.Count && c.FilesWithMatches {
+		mustFprintf(args.Env.Stderr(), "%s: -c cannot be used along with -l\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	if c.Jobs < 0 {
+		mustFprintf(args.Env.Stderr(), "%s: the number of jobs must not be negative: %d\n", args.CommandName, c.Jobs)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	return c
+}
+
+// --- execution ---
+
+// errGrepNoMatch indicates that the pattern did not match in any repository.
+var errGrepNoMatch = errors.New("no match")
+
+// grepMatch is a match found by the grep command.
+type grepMatch struct {
+	// Repo is the name of the repository containing the match.
+	Repo string `json:"repo"`
+
+	// Path is the file path relative to the multirepo root.
+	Path string `json:"path"`
+
+	// Line is the one-based number of the matching line (zero with `-l` and `-c`).
+	Line int `json:"line,omitempty"`
+
+	// Text is the matching line (empty with `-l` and `-c`).
+	Text string `json:"text,omitempty"`
+
+	// Count is the number of matching lines (only with `-c`).
+	Count int `json:"count,omitempty"`
+}
+
+// grepResult is the result of searching a repository.
+type grepResult struct {
+	// Matches contains the matches.
+	Matches []grepMatch
+
+	// Err is the error that occurred, if any.
+	Err error
+}
+
+func (c *cmdGrepRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Read the repositories to search
+	dd := defaultDotDir(args.Env)
+	config, err := readConfigSnapshot(args.Env, dd, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo grep: %s\n", err)
+		return err
+	}
+	repos := config.SelectedRepos()
+
+	// Determine the number of repositories to search in parallel
+	jobs := int(c.Jobs)
+	if jobs <= 0 {
+		jobs = config.Effective.Jobs
+	}
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	// Search the repositories in parallel
+	results := make([]grepResult, len(repos))
+	sema := make(chan struct{}, jobs)
+	wg := &sync.WaitGroup{}
+	for idx, repo := range repos {
+		wg.Add(1)
+		sema <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sema }()
+			matches, err := c.search(ctx, args.Env, dd, repo)
+			results[idx] = grepResult{Matches: matches, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	// Merge the matches in repository order and report the errors
+	matches := []grepMatch{}
+	errlist := []error{}
+	for idx, result := range results {
+		if result.Err != nil {
+			err := fmt.Errorf("%s: %w", repos[idx], result.Err)
+			mustFprintf(args.Env.Stderr(), "multirepo grep: %s\n", err)
+			errlist = append(errlist, err)
+			continue
+		}
+		matches = append(matches, result.Matches...)
+	}
+
+	// Print the matches
+	switch {
+	case c.JSON:
+		mustFprintf(args.Env.Stdout(), "%s\n", mustMarshalIndentJSON(matches, "", "  "))
+	default:
+		for _, match := range matches {
+			mustFprintf(args.Env.Stdout(), "%s\n", c.format(match))
+		}
+	}
+
+	// Like grep, exit with 2 on error and with 1 when nothing matched
+	if len(errlist) > 0 {
+		args.Env.Exit(2)
+		return errors.Join(errlist...)
+	}
+	if len(matches) <= 0 {
+		return errGrepNoMatch
+	}
+	return nil
+}
+
+// format formats the given match like `git grep` does.
+func (c *cmdGrepRunner) format(match grepMatch) string {
+	filename := filepath.FromSlash(match.Path)
+	switch {
+	case c.FilesWithMatches:
+		return filename
+	case c.Count:
+		return fmt.Sprintf("%s:%d", filename, match.Count)
+	default:
+		return fmt.Sprintf("%s:%d:%s", filename, match.Line, match.Text)
+	}
+}
+
+// search runs `git grep` in the given repository and returns the matches.
+func (c *cmdGrepRunner) search(ctx context.Context, env environ, dd dotDir, repo string) ([]grepMatch, error) {
+	// Run `git grep` separating the fields with NUL bytes and skipping binary files
+	argv := []string{"grep", "--null", "-I"}
+	switch {
+	case c.FilesWithMatches:
+		argv = append(argv, "-l")
+	case c.Count:
+		argv = append(argv, "-c")
+	default:
+		argv = append(argv, "-n")
+	}
+	argv = append(argv, "-e", c.Pattern, "--")
+	argv = append(argv, c.Pathspecs...)
+	output, err := queryGit(env, dd.repoGitCommand(ctx, env, repo, argv...))
+
+	// Note: `git grep` exits with 1 when nothing matched
+	if err != nil {
+		if commandExitCode(err) == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c.parse(repo, output)
+}
+
+// parse parses the output of `git grep --null` rewriting the paths, which
+// are relative to the given repository, to be relative to the multirepo root.
+func (c *cmdGrepRunner) parse(repo, output string) ([]grepMatch, error) {
+	matches := []grepMatch{}
+
+	// With `-l`, the output contains NUL-terminated paths
+	if c.FilesWithMatches {
+		for _, filename := range strings.Split(strings.TrimSuffix(output, "\x00"), "\x00") {
+			if filename != "" {
+				matches = append(matches, grepMatch{Repo: repo, Path: path.Join(repo, filename)})
+			}
+		}
+		return matches, nil
+	}
+
+	// Otherwise, each line contains NUL-separated fields
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) < 2 || (!c.Count && len(fields) < 3) {
+			return nil, fmt.Errorf("unexpected git grep output: %q", line)
+		}
+		value, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git grep output: %q", line)
+		}
+		match := grepMatch{Repo: repo, Path: path.Join(repo, fields[0])}
+		if c.Count {
+			match.Count = value
+		} else {
+			match.Line, match.Text = value, fields[2]
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
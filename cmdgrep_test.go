@@ -0,0 +1,75 @@
+// cmdgrep_test.go - Tests for the 'grep' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCmdGrepRunnerParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		runner *cmdGrepRunner
+		output string
+		expect []grepMatch
+		fails  bool
+	}{{
+		name:   "matching lines",
+		runner: &cmdGrepRunner{},
+		output: "main.go\x0012\x00\tfoo := 1\nsub/x.go\x003\x00a:b\x00c\n",
+		expect: []grepMatch{
+			{Repo: "repo", Path: "repo/main.go", Line: 12, Text: "\tfoo := 1"},
+			{Repo: "repo", Path: "repo/sub/x.go", Line: 3, Text: "a:b\x00c"},
+		},
+	}, {
+		name:   "counts",
+		runner: &cmdGrepRunner{Count: true},
+		output: "main.go\x004\nsub/x.go\x001\n",
+		expect: []grepMatch{
+			{Repo: "repo", Path: "repo/main.go", Count: 4},
+			{Repo: "repo", Path: "repo/sub/x.go", Count: 1},
+		},
+	}, {
+		name:   "files with matches",
+		runner: &cmdGrepRunner{FilesWithMatches: true},
+		output: "main.go\x00file with spaces\nand newline.go\x00",
+		expect: []grepMatch{
+			{Repo: "repo", Path: "repo/main.go"},
+			{Repo: "repo", Path: "repo/file with spaces\nand newline.go"},
+		},
+	}, {
+		name:   "empty output",
+		runner: &cmdGrepRunner{},
+		output: "",
+		expect: []grepMatch{},
+	}, {
+		name:   "missing fields",
+		runner: &cmdGrepRunner{},
+		output: "main.go\x0012\n",
+		fails:  true,
+	}, {
+		name:   "invalid line number",
+		runner: &cmdGrepRunner{},
+		output: "main.go\x00twelve\x00foo\n",
+		fails:  true,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			matches, err := tc.runner.parse("repo", tc.output)
+			if tc.fails {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(matches, tc.expect) {
+				t.Fatalf("expected %+v, got %+v", tc.expect, matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// cmdhistory.go - implementation of the history command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/kballard/go-shellquote"
+)
+
+// cmdHistory is the static history command
+var cmdHistory = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Show the history of the index mutations.",
+	RunFunc:              cmdHistoryMain,
+}
+
+// cmdHistoryRunner runs the history command.
+type cmdHistoryRunner struct {
+	// Limit is the maximum number of entries to show (zero means no limit).
+	Limit int64
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdHistoryMain is the entry point for the history command.
+func cmdHistoryMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdHistoryRunner(args).run(args)
+}
+
+// mustNewCmdHistoryRunner creates a new [*cmdHistoryRunner].
+func mustNewCmdHistoryRunner(args *clip.CommandArgs[environ]) *cmdHistoryRunner {
+	// Initialize the default configuration.
+	c := &cmdHistoryRunner{
+		Limit:       0,
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = ""
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 0
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-n` flag.
+	fset.Int64Var(&c.Limit, "max-count", 'n', "Show at most the given number of entries.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdHistoryRunner) run(args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo history: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Read the journal
+	entries, err := readJournal(args.Env, dd.journalFilePath())
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo history: %s\n", err)
+		return err
+	}
+
+	// Print the entries from the most recent one, numbering them
+	// such that `multirepo undo N` reverts the first N entries.
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+		count := len(entries) - idx
+		if c.Limit > 0 && int64(count) > c.Limit {
+			break
+		}
+		entry := entries[idx]
+		mustFprintf(args.Env.Stdout(), "%-4d %s  %s\n", count,
+			entry.Time.Local().Format(time.DateTime), shellquote.Join(entry.Argv...))
+		for _, line := range entry.Diff() {
+			mustFprintf(args.Env.Stdout(), "     %s\n", line)
+		}
+	}
+	return nil
+}
@@ -5,12 +5,12 @@ package main
 
 import (
 	"context"
-	"io"
+	"path/filepath"
+	"time"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/clip/pkg/nflag"
-	"github.com/kballard/go-shellquote"
 )
 
 // cmdInit is the static init command
@@ -21,26 +21,29 @@ var cmdInit = &clip.LeafCommand[environ]{
 
 // cmdInitRunner runs the init command.
 type cmdInitRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Track indicates whether to track the `.multirepo` directory using git.
+	Track bool
+
 	// Style is the nil-safe libgloss style to use.
 	Style *nilSafeLipglossStyle
-
-	// XWriter is the writer used to log executed commands.
-	XWriter io.Writer
 }
 
 // --- entry & setup ---
 
 // cmdInitMain is the entry point for the init command.
 func cmdInitMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
-	return mustNewCmdInitRunner(args).run(args)
+	return mustNewCmdInitRunner(args).run(ctx, args)
 }
 
 // mustNewCmdInitRunner creates a new [*cmdInitRunner].
 func mustNewCmdInitRunner(args *clip.CommandArgs[environ]) *cmdInitRunner {
 	// Initialize the default configuration.
 	c := &cmdInitRunner{
-		Style:   nil,
-		XWriter: io.Discard,
+		LockTimeout: -1,
+		Track:       false,
 	}
 
 	// Create empty command line parser.
@@ -53,34 +56,51 @@ func mustNewCmdInitRunner(args *clip.CommandArgs[environ]) *cmdInitRunner {
 	// Add the `-h, --help` flag.
 	fset.AutoHelp("help", 'h', "Show this help message and exit.")
 
+	// Add the `--track` flag.
+	fset.BoolVar(&c.Track, "track", 0, "Track the multirepo configuration using git.")
+
 	// Add the `-x` flag.
 	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
 
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
 	// Parse the command line arguments.
 	assert.NotError(fset.Parse(args.Args))
 
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
 	// Honour the `-x` flag.
-	if *xflag {
-		c.XWriter = args.Env.Stderr()
-		c.Style = newNilSafeLipglossStyle()
-	}
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
 
 	return c
 }
 
 // --- execution ---
 
-func (c *cmdInitRunner) run(args *clip.CommandArgs[environ]) error {
+func (c *cmdInitRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
 	// Create the `.multirepo` directory
-	dd := defaultDotDir()
-	mustFprintf(c.XWriter, "%s\n", c.Style.Renderf("+ mkdir -p %s", shellquote.Join(dd.String())))
+	dd := defaultDotDir(args.Env)
 	if err := args.Env.MkdirAll(dd.String(), 0700); err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo init: %s\n", err.Error())
 		return err
 	}
 
 	// Lock the multirepo dir
-	unlock, err := dd.lock(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
 	if err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo init: %s\n", err)
 		return err
@@ -96,8 +116,16 @@ func (c *cmdInitRunner) run(args *clip.CommandArgs[environ]) error {
 
 	// Write the initial configuration file
 	if !exists {
-		mustFprintf(c.XWriter, "%s\n", c.Style.Renderf("+ echo '{}' > %s", shellquote.Join(dd.configFilePath())))
-		if err := args.Env.WriteFile(dd.configFilePath(), []byte("{}\n"), 0600); err != nil {
+		data := newConfig().Marshal()
+		if err := args.Env.WriteFile(dd.configFilePath(), data, 0600); err != nil {
+			mustFprintf(args.Env.Stderr(), "multirepo init: %s\n", err.Error())
+			return err
+		}
+	}
+
+	// Possibly track the `.multirepo` directory using git
+	if c.Track {
+		if err := c.track(ctx, args.Env, dd); err != nil {
 			mustFprintf(args.Env.Stderr(), "multirepo init: %s\n", err.Error())
 			return err
 		}
@@ -105,3 +133,29 @@ func (c *cmdInitRunner) run(args *clip.CommandArgs[environ]) error {
 
 	return nil
 }
+
+// track turns the `.multirepo` directory into a git repository.
+func (c *cmdInitRunner) track(ctx context.Context, env environ, dd dotDir) error {
+	// Nothing to do if the directory is already tracked
+	tracked, err := dd.isTracked(env)
+	if err != nil || tracked {
+		return err
+	}
+
+	// Create the git repository
+	if err := dd.runGit(ctx, env, "init", "-q"); err != nil {
+		return err
+	}
+
+	// Write the `.gitignore` file
+	gitignore := filepath.Join(dd.String(), ".gitignore")
+	if err := env.WriteFile(gitignore, []byte(manifestGitignore), 0644); err != nil {
+		return err
+	}
+
+	// Create the initial commit
+	if err := dd.runGit(ctx, env, "add", ".gitignore", "config.json"); err != nil {
+		return err
+	}
+	return dd.runGit(ctx, env, "commit", "-q", "-m", "multirepo init --track")
+}
@@ -0,0 +1,295 @@
+// cmdlog.go - implementation of the log command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdLog is the static log command
+var cmdLog = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Show the commits of all the repositories as a single timeline.",
+	RunFunc:              cmdLogMain,
+}
+
+// cmdLogRunner runs the log command.
+type cmdLogRunner struct {
+	// Author only shows the commits whose author matches the given pattern.
+	Author string
+
+	// JSON indicates whether to emit JSON.
+	JSON bool
+
+	// Limit is the maximum number of commits to show (zero means no limit).
+	Limit int64
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Oneline indicates whether to show each commit in a single line.
+	Oneline bool
+
+	// Since only shows the commits more recent than the given date.
+	Since string
+}
+
+// --- entry & setup ---
+
+// cmdLogMain is the entry point for the log command.
+func cmdLogMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdLogRunner(args).run(ctx, args)
+}
+
+// mustNewCmdLogRunner creates a new [*cmdLogRunner].
+func mustNewCmdLogRunner(args *clip.CommandArgs[environ]) *cmdLogRunner {
+	// Initialize the default configuration.
+	c := &cmdLogRunner{
+		Author:      "",
+		JSON:        false,
+		Limit:       0,
+		LockTimeout: -1,
+		Oneline:     false,
+		Since:       "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = ""
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 0
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `--author` flag.
+	fset.StringVar(&c.Author, "author", 0, "Only show the commits whose author matches the given pattern.")
+
+	// Add the `--json` flag.
+	fset.BoolVar(&c.JSON, "json", 0, "Emit the commits as a JSON array.")
+
+	// Add the `-n` flag.
+	fset.Int64Var(&c.Limit, "max-count", 'n', "Show at most the given number of commits.")
+
+	// Add the `--oneline` flag.
+	fset.BoolVar(&c.Oneline, "oneline", 0, "Show each commit in a single line.")
+
+	// Add the `--since` flag.
+	fset.StringVar(&c.Since, "since", 0, "Only show the commits more recent than the given date (e.g., '1 week ago').")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Make sure the output format is not ambiguous.
+	if c.JSON && c.Oneline {
+		mustFprintf(args.Env.Stderr(), "%s: --json cannot be used along with --oneline\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	return c
+}
+
+// --- execution ---
+
+// logCommit is a commit shown by the log command.
+type logCommit struct {
+	// Repo is the name of the repository containing the commit.
+	Repo string `json:"repo"`
+
+	// Hash is the full commit hash.
+	Hash string `json:"hash"`
+
+	// ShortHash is the abbreviated commit hash.
+	ShortHash string `json:"short_hash"`
+
+	// AuthorName is the author name.
+	AuthorName string `json:"author_name"`
+
+	// AuthorEmail is the author email.
+	AuthorEmail string `json:"author_email"`
+
+	// AuthorDate is when the commit was authored.
+	AuthorDate time.Time `json:"author_date"`
+
+	// CommitDate is when the commit was committed, which we use for sorting and printing.
+	CommitDate time.Time `json:"commit_date"`
+
+	// Subject is the first line of the commit message.
+	Subject string `json:"subject"`
+
+	// Body is the rest of the commit message.
+	Body string `json:"body"`
+}
+
+// logFormat is the `git log` format we use, where NUL bytes, which cannot appear
+// inside the fields, separate the fields. Because we use `-z`, git terminates
+// each commit with a NUL byte, so we can parse [logFields] fields at a time.
+const logFormat = "%H%x00%h%x00%an%x00%ae%x00%aI%x00%cI%x00%s%x00%b"
+
+// logFields is the number of fields in [logFormat].
+const logFields = 8
+
+func (c *cmdLogRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Read the repositories to iterate over
+	dd := defaultDotDir(args.Env)
+	config, err := readConfigSnapshot(args.Env, dd, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo log: %s\n", err)
+		return err
+	}
+
+	// Collect the commits of each repository
+	commits := []logCommit{}
+	errlist := []error{}
+	for _, repo := range config.SelectedRepos() {
+		found, err := c.collect(ctx, args.Env, dd, repo)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", repo, err)
+			mustFprintf(args.Env.Stderr(), "multirepo log: %s\n", err)
+			errlist = append(errlist, err)
+			continue
+		}
+		commits = append(commits, found...)
+	}
+
+	// Merge into a single timeline starting from the most recent commit, where
+	// the stable sort preserves the order of git for commits with the same date.
+	//
+	// We sort by and print the commit date, rather than the author date, because
+	// `git log` uses the commit date for its order, for `--since`, and for `-n`.
+	slices.SortStableFunc(commits, func(a, b logCommit) int {
+		return b.CommitDate.Compare(a.CommitDate)
+	})
+	if c.Limit > 0 && int64(len(commits)) > c.Limit {
+		commits = commits[:c.Limit]
+	}
+
+	// Print the commits
+	switch {
+	case c.JSON:
+		mustFprintf(args.Env.Stdout(), "%s\n", mustMarshalIndentJSON(commits, "", "  "))
+	case c.Oneline:
+		for _, commit := range commits {
+			mustFprintf(args.Env.Stdout(), "%s %s %s %s (%s)\n", commit.CommitDate.Local().Format(time.DateOnly),
+				commit.Repo, commit.ShortHash, commit.Subject, commit.AuthorName)
+		}
+	default:
+		for idx, commit := range commits {
+			if idx > 0 {
+				mustFprintf(args.Env.Stdout(), "\n")
+			}
+			c.print(args.Env.Stdout(), commit)
+		}
+	}
+
+	return errors.Join(errlist...)
+}
+
+// print prints the given commit using a format similar to `git log`.
+func (c *cmdLogRunner) print(w io.Writer, commit logCommit) {
+	mustFprintf(w, "commit %s (%s)\n", commit.Hash, commit.Repo)
+	mustFprintf(w, "Author: %s <%s>\n", commit.AuthorName, commit.AuthorEmail)
+	mustFprintf(w, "Date:   %s\n", commit.CommitDate.Local().Format(time.RFC1123Z))
+	mustFprintf(w, "\n    %s\n", commit.Subject)
+	if body := strings.TrimSpace(commit.Body); body != "" {
+		mustFprintf(w, "\n")
+		for _, line := range strings.Split(body, "\n") {
+			mustFprintf(w, "    %s\n", line)
+		}
+	}
+}
+
+// collect returns the commits of the given repository, which may be empty
+// when the repository does not have any commit yet.
+func (c *cmdLogRunner) collect(ctx context.Context, env environ, dd dotDir, repo string) ([]logCommit, error) {
+	// Skip the repositories without commits, where `git log` would fail
+	if _, err := dd.queryRepoGit(ctx, env, repo, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		if commandExitCode(err) == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Run `git log` using the machine-parsable format
+	argv := []string{"log", "-z", "--format=" + logFormat}
+	if c.Since != "" {
+		argv = append(argv, "--since="+c.Since)
+	}
+	if c.Author != "" {
+		argv = append(argv, "--author="+c.Author)
+	}
+	if c.Limit > 0 {
+		argv = append(argv, "--max-count="+strconv.FormatInt(c.Limit, 10))
+	}
+	output, err := queryGit(env, dd.repoGitCommand(ctx, env, repo, argv...))
+	if err != nil {
+		return nil, err
+	}
+	return parseLogCommits(repo, output)
+}
+
+// parseLogCommits parses the output of `git log -z` using [logFormat].
+func parseLogCommits(repo, output string) ([]logCommit, error) {
+	commits := []logCommit{}
+	fields := strings.Split(output, "\x00")
+	for len(fields) >= logFields {
+		authorDate, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return nil, err
+		}
+		commitDate, err := time.Parse(time.RFC3339, fields[5])
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, logCommit{
+			Repo:        repo,
+			Hash:        fields[0],
+			ShortHash:   fields[1],
+			AuthorName:  fields[2],
+			AuthorEmail: fields[3],
+			AuthorDate:  authorDate,
+			CommitDate:  commitDate,
+			Subject:     fields[6],
+			Body:        strings.TrimSpace(fields[7]),
+		})
+		fields = fields[logFields:]
+	}
+	return commits, nil
+}
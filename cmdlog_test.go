@@ -0,0 +1,58 @@
+// cmdlog_test.go - Tests for the 'log' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogCommits(t *testing.T) {
+	t.Run("commits", func(t *testing.T) {
+		// This is what `git log -z --format=<logFormat>` emits: the fields
+		// are separated by NUL bytes, and so are the commits
+		output := strings.Join([]string{
+			"0ae6581abe50dc5270c33bb258757e73f40d066", "0ae6581", "Alice", "alice@example.com",
+			"2026-10-18T16:30:54+02:00", "2026-10-18T17:00:00+02:00", "second", "body line\n",
+			"339c49c2734f1f8bfe8bec7770f52a9e5b1cc0df", "339c49c", "Bob", "bob@example.com",
+			"2026-10-17T10:00:00Z", "2026-10-17T10:00:00Z", "first", "",
+		}, "\x00") + "\x00"
+		commits, err := parseLogCommits("probe-cli", output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(commits) != 2 {
+			t.Fatalf("expected 2 commits, got %d", len(commits))
+		}
+		first := commits[0]
+		if first.Repo != "probe-cli" || first.ShortHash != "0ae6581" || first.AuthorName != "Alice" ||
+			first.AuthorEmail != "alice@example.com" || first.Subject != "second" || first.Body != "body line" {
+			t.Fatalf("unexpected commit: %+v", first)
+		}
+		if expect := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC); !first.CommitDate.Equal(expect) {
+			t.Fatalf("expected commit date %s, got %s", expect, first.CommitDate)
+		}
+		if expect := time.Date(2026, 10, 18, 14, 30, 54, 0, time.UTC); !first.AuthorDate.Equal(expect) {
+			t.Fatalf("expected author date %s, got %s", expect, first.AuthorDate)
+		}
+		if second := commits[1]; second.Hash != "339c49c2734f1f8bfe8bec7770f52a9e5b1cc0df" || second.Body != "" {
+			t.Fatalf("unexpected commit: %+v", second)
+		}
+	})
+
+	t.Run("empty output", func(t *testing.T) {
+		commits, err := parseLogCommits("probe-cli", "")
+		if err != nil || len(commits) != 0 {
+			t.Fatalf("expected no commits, got %v, %v", commits, err)
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		output := strings.Join([]string{"h", "h", "a", "a@example.com", "yesterday", "today", "s", ""}, "\x00")
+		if _, err := parseLogCommits("probe-cli", output); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
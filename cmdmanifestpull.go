@@ -0,0 +1,142 @@
+// cmdmanifestpull.go - implementation of the 'manifest pull' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdManifestPull is the static 'manifest pull' command
+var cmdManifestPull = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Pull the tracked multirepo configuration.",
+	RunFunc:              cmdManifestPullMain,
+}
+
+// cmdManifestPullRunner runs the 'manifest pull' command.
+type cmdManifestPullRunner struct {
+	// Argv contains the arguments for git pull.
+	Argv []string
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdManifestPullMain is the entry point for the 'manifest pull' command.
+func cmdManifestPullMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdManifestPullRunner(args).run(ctx, args)
+}
+
+// mustNewCmdManifestPullRunner creates a new [*cmdManifestPullRunner].
+func mustNewCmdManifestPullRunner(args *clip.CommandArgs[environ]) *cmdManifestPullRunner {
+	// Initialize the default configuration.
+	c := &cmdManifestPullRunner{
+		Argv:        []string{},
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "[git-pull-args...]"
+	fset.DisablePermute = true // Disable option permutaion to allow passing options to git pull
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = math.MaxInt
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the arguments for git pull.
+	c.Argv = fset.Args()
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdManifestPullRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo manifest pull: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Pull the manifest
+	if err := c.pull(ctx, args.Env, dd); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo manifest pull: %s\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// pull pulls the manifest.
+func (c *cmdManifestPullRunner) pull(ctx context.Context, env environ, dd dotDir) error {
+	// Make sure the manifest is tracked
+	tracked, err := dd.isTracked(env)
+	if err != nil {
+		return err
+	}
+	if !tracked {
+		return errNotTracked
+	}
+
+	// Read the configuration before pulling
+	before, err := readConfig(env, dd.configFilePath())
+	if err != nil {
+		return err
+	}
+
+	// Run git pull refusing to create merge commits
+	gitArgs := append([]string{"pull", "--ff-only"}, c.Argv...)
+	if err := dd.runGit(ctx, env, gitArgs...); err != nil {
+		return err
+	}
+
+	// Read the configuration after pulling
+	after, err := readConfig(env, dd.configFilePath())
+	if err != nil {
+		return err
+	}
+
+	// Record the mutation in the journal
+	return dd.recordMutation(env, journalArgv(env), before, after)
+}
@@ -0,0 +1,125 @@
+// cmdmanifestpush.go - implementation of the 'manifest push' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdManifestPush is the static 'manifest push' command
+var cmdManifestPush = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Push the tracked multirepo configuration.",
+	RunFunc:              cmdManifestPushMain,
+}
+
+// cmdManifestPushRunner runs the 'manifest push' command.
+type cmdManifestPushRunner struct {
+	// Argv contains the arguments for git push.
+	Argv []string
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdManifestPushMain is the entry point for the 'manifest push' command.
+func cmdManifestPushMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdManifestPushRunner(args).run(ctx, args)
+}
+
+// mustNewCmdManifestPushRunner creates a new [*cmdManifestPushRunner].
+func mustNewCmdManifestPushRunner(args *clip.CommandArgs[environ]) *cmdManifestPushRunner {
+	// Initialize the default configuration.
+	c := &cmdManifestPushRunner{
+		Argv:        []string{},
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "[git-push-args...]"
+	fset.DisablePermute = true // Disable option permutaion to allow passing options to git push
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = math.MaxInt
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the arguments for git push.
+	c.Argv = fset.Args()
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdManifestPushRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo manifest push: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Push the manifest
+	if err := c.push(ctx, args.Env, dd); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo manifest push: %s\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// push pushes the manifest.
+func (c *cmdManifestPushRunner) push(ctx context.Context, env environ, dd dotDir) error {
+	// Make sure the manifest is tracked
+	tracked, err := dd.isTracked(env)
+	if err != nil {
+		return err
+	}
+	if !tracked {
+		return errNotTracked
+	}
+
+	// Run git push
+	gitArgs := append([]string{"push"}, c.Argv...)
+	return dd.runGit(ctx, env, gitArgs...)
+}
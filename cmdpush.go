@@ -0,0 +1,248 @@
+// cmdpush.go - implementation of the push command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdPush is the static push command
+var cmdPush = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Push the current branch of all the repositories ahead of upstream.",
+	RunFunc:              cmdPushMain,
+}
+
+// cmdPushRunner runs the push command.
+type cmdPushRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdPushMain is the entry point for the push command.
+func cmdPushMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdPushRunner(args).run(ctx, args)
+}
+
+// mustNewCmdPushRunner creates a new [*cmdPushRunner].
+func mustNewCmdPushRunner(args *clip.CommandArgs[environ]) *cmdPushRunner {
+	// Initialize the default configuration.
+	c := &cmdPushRunner{
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 0
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	return c
+}
+
+// --- execution ---
+
+// pushRepo is a repository whose current branch we push.
+type pushRepo struct {
+	// Name is the repository name.
+	Name string
+
+	// Branch is the current branch.
+	Branch string
+
+	// Remote is the remote of the upstream branch.
+	Remote string
+
+	// RemoteRef is the upstream branch on the remote (e.g., `refs/heads/main`).
+	RemoteRef string
+
+	// Ahead is the number of commits the branch is ahead of upstream.
+	Ahead int
+
+	// Atomic indicates whether the remote supports atomic pushes.
+	Atomic bool
+}
+
+// Refspec returns the refspec to push.
+func (pr *pushRepo) Refspec() string {
+	return "refs/heads/" + pr.Branch + ":" + pr.RemoteRef
+}
+
+// Argv returns the `git push` arguments using the given extra flags.
+func (pr *pushRepo) Argv(flags ...string) []string {
+	argv := append([]string{"push"}, flags...)
+	if pr.Atomic {
+		argv = append(argv, "--atomic")
+	}
+	return append(argv, pr.Remote, pr.Refspec())
+}
+
+func (c *cmdPushRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Read the repositories to push
+	dd := defaultDotDir(args.Env)
+	config, err := readConfigSnapshot(args.Env, dd, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo push: %s\n", err)
+		return err
+	}
+
+	// Select the repositories whose current branch is ahead of upstream
+	repos := []*pushRepo{}
+	for _, name := range config.SelectedRepos() {
+		repo, err := c.inspect(ctx, args.Env, dd, name)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo push: %s\n", err)
+			return err
+		}
+		if repo != nil && repo.Ahead > 0 {
+			repos = append(repos, repo)
+		}
+	}
+	if len(repos) <= 0 {
+		mustFprintf(args.Env.Stderr(), "multirepo push: nothing to push\n")
+		return nil
+	}
+
+	// Make sure all the pushes would succeed before pushing anything
+	errlist := []error{}
+	for _, repo := range repos {
+		if err := c.preflight(ctx, args.Env, dd, repo); err != nil {
+			err = fmt.Errorf("%s: %w", repo.Name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo push: %s\n", err)
+			errlist = append(errlist, err)
+		}
+	}
+	if len(errlist) > 0 {
+		mustFprintf(args.Env.Stderr(), "multirepo push: not pushing any repository\n")
+		return errors.Join(errlist...)
+	}
+
+	// Push, continuing with the next repository on failure
+	for _, repo := range repos {
+		mustFprintf(args.Env.Stdout(), "%s: pushing %d commits (%s -> %s %s)\n",
+			repo.Name, repo.Ahead, repo.Branch, repo.Remote, repo.RemoteRef)
+		if err := dd.runRepoGit(ctx, args.Env, repo.Name, repo.Argv("-q")...); err != nil {
+			err = fmt.Errorf("%s: %w", repo.Name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo push: %s\n", err)
+			errlist = append(errlist, err)
+		}
+	}
+	return errors.Join(errlist...)
+}
+
+// inspect returns the current branch of the given repository along with its
+// upstream, or nil when the repository is not on a branch with an upstream.
+func (c *cmdPushRunner) inspect(ctx context.Context, env environ, dd dotDir, name string) (*pushRepo, error) {
+	// Skip the repositories not on a branch
+	branch := dd.currentBranch(ctx, env, name)
+	if branch == "" {
+		mustFprintf(env.Stderr(), "multirepo push: %s: not on a branch, skipping\n", name)
+		return nil, nil
+	}
+
+	// Skip the branches without upstream
+	upstream, err := dd.queryRepoGit(ctx, env, name, "for-each-ref",
+		"--format=%(upstream:remotename) %(upstream:remoteref)", "refs/heads/"+branch)
+	if err != nil {
+		return nil, err
+	}
+	remote, remoteRef, _ := strings.Cut(upstream, " ")
+	if remote == "" || remoteRef == "" {
+		mustFprintf(env.Stderr(), "multirepo push: %s: the %s branch has no upstream, skipping\n", name, branch)
+		return nil, nil
+	}
+
+	// Count the commits to push
+	count, err := dd.queryRepoGit(ctx, env, name, "rev-list", "--count", branch+"@{upstream}.."+branch)
+	if err != nil {
+		return nil, err
+	}
+	ahead, err := strconv.Atoi(count)
+	if err != nil {
+		return nil, err
+	}
+	repo := &pushRepo{
+		Name:      name,
+		Branch:    branch,
+		Remote:    remote,
+		RemoteRef: remoteRef,
+		Ahead:     ahead,
+		Atomic:    true,
+	}
+	return repo, nil
+}
+
+// preflight uses `git push --dry-run --porcelain` to make sure that the push
+// would succeed (e.g., that it would be a fast-forward), given the current
+// state of the remote. We first try with `--atomic` and do not use it when
+// the remote does not support atomic pushes.
+func (c *cmdPushRunner) preflight(ctx context.Context, env environ, dd dotDir, repo *pushRepo) error {
+	output, err := c.dryRun(ctx, env, dd, repo)
+	if err != nil && strings.Contains(output, "--atomic") {
+		repo.Atomic = false
+		output, err = c.dryRun(ctx, env, dd, repo)
+	}
+
+	// Report the reason why git rejected the push, if possible
+	for _, line := range strings.Split(output, "\n") {
+		if flag, rest, found := strings.Cut(line, "\t"); found && flag == "!" {
+			return fmt.Errorf("push rejected: %s", strings.ReplaceAll(rest, "\t", " "))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("push would fail: %w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// dryRun runs `git push --dry-run --porcelain` returning its combined output.
+func (c *cmdPushRunner) dryRun(ctx context.Context, env environ, dd dotDir, repo *pushRepo) (string, error) {
+	var output bytes.Buffer
+	cmd := dd.repoGitCommand(ctx, env, repo.Name, repo.Argv("--dry-run", "--porcelain")...)
+	cmd.Stdout, cmd.Stderr = &output, &output
+	err := env.RunQueryCommand(cmd)
+	return output.String(), err
+}
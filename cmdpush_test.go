@@ -0,0 +1,197 @@
+// cmdpush_test.go - Tests for the 'push' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip"
+)
+
+func TestPush(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		root := newTestMultirepo(t, "r1", "r2")
+		testCommit(t, filepath.Join(root, "r1"), "change r1")
+		testCommit(t, filepath.Join(root, "r2"), "change r2")
+
+		stdout, stderr, err := testRunCommand(cmdPush, "push")
+		if err != nil {
+			t.Fatalf("push failed: %s: %s", err, stderr)
+		}
+		for _, name := range []string{"r1", "r2"} {
+			if !strings.Contains(stdout, name+": pushing 1 commits (main -> origin refs/heads/main)") {
+				t.Fatalf("unexpected stdout: %q", stdout)
+			}
+			if local, remote := testRemoteHead(t, root, name); local != remote {
+				t.Fatalf("%s: expected the remote to be at %s, got %s", name, local, remote)
+			}
+		}
+	})
+
+	t.Run("non-fast-forward refuses every push", func(t *testing.T) {
+		root := newTestMultirepo(t, "r1", "r2")
+		testCommit(t, filepath.Join(root, "r1"), "change r1")
+		testCommit(t, filepath.Join(root, "r2"), "change r2")
+
+		// Make r2 diverge from its remote using another clone
+		other := filepath.Join(root, "other")
+		testGit(t, root, "clone", "-q", filepath.Join(root, "remotes", "r2.git"), other)
+		testCommit(t, other, "concurrent change")
+		testGit(t, other, "push", "-q", "origin", "main")
+		before := testGit(t, filepath.Join(root, "remotes", "r1.git"), "rev-parse", "main")
+
+		_, stderr, err := testRunCommand(cmdPush, "push")
+		if err == nil {
+			t.Fatal("expected push to fail")
+		}
+		if !strings.Contains(stderr, "r2: push rejected") || !strings.Contains(stderr, "not pushing any repository") {
+			t.Fatalf("unexpected stderr: %q", stderr)
+		}
+		if after := testGit(t, filepath.Join(root, "remotes", "r1.git"), "rev-parse", "main"); after != before {
+			t.Fatalf("expected r1 not to be pushed, but the remote moved from %s to %s", before, after)
+		}
+	})
+
+	t.Run("remote without atomic pushes", func(t *testing.T) {
+		root := newTestMultirepo(t, "r1")
+		testGit(t, filepath.Join(root, "remotes", "r1.git"), "config", "receive.advertiseAtomic", "false")
+		testCommit(t, filepath.Join(root, "r1"), "change r1")
+
+		// Make sure the preflight retries without --atomic
+		ctx := context.Background()
+		repo, err := (&cmdPushRunner{}).inspect(ctx, newStdlibEnviron(), dotDirName, "r1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (&cmdPushRunner{}).preflight(ctx, &captureEnviron{environ: newStdlibEnviron()}, dotDirName, repo); err != nil {
+			t.Fatal(err)
+		}
+		if repo.Atomic {
+			t.Fatal("expected the preflight not to use --atomic")
+		}
+
+		// Make sure the whole command pushes as well
+		if _, stderr, err := testRunCommand(cmdPush, "push"); err != nil {
+			t.Fatalf("push failed: %s: %s", err, stderr)
+		}
+		if local, remote := testRemoteHead(t, root, "r1"); local != remote {
+			t.Fatalf("expected the remote to be at %s, got %s", local, remote)
+		}
+	})
+}
+
+// newTestMultirepo creates a multirepo inside a temporary directory, which becomes
+// the current directory, containing a repository for each given name, along with
+// a bare remote inside the `remotes` directory, where the main branch contains
+// a single commit and tracks the same branch of the remote. It skips the test
+// when git is not installed and isolates git from the user configuration.
+func newTestMultirepo(t *testing.T, names ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "a")
+	t.Setenv("GIT_AUTHOR_EMAIL", "a@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "a")
+	t.Setenv("GIT_COMMITTER_EMAIL", "a@example.com")
+	root := t.TempDir()
+	t.Chdir(root)
+
+	cfg := newConfig()
+	for _, name := range names {
+		remote := filepath.Join(root, "remotes", name+".git")
+		testGit(t, root, "init", "-q", "--bare", "-b", "main", remote)
+		testGit(t, root, "init", "-q", "-b", "main", name)
+		testGit(t, filepath.Join(root, name), "remote", "add", "origin", remote)
+		testCommit(t, filepath.Join(root, name), "initial")
+		testGit(t, filepath.Join(root, name), "push", "-q", "-u", "origin", "main")
+		cfg.Repos[name] = repoInfo{URL: "https://example.com/" + name}
+	}
+	if err := os.Mkdir(dotDirName, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.WriteFile(newStdlibEnviron(), dotDir(dotDirName).configFilePath()); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// testGit runs git inside the given directory, failing the test on error,
+// and returns its trimmed standard output.
+func testGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("git %v: %s: %s", args, err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+// testCommit creates an empty commit with the given message inside the given repository.
+func testCommit(t *testing.T, dir, message string) {
+	t.Helper()
+	testGit(t, dir, "commit", "-q", "--allow-empty", "-m", message)
+}
+
+// testRemoteHead returns the main branch of the given repository and of its remote.
+func testRemoteHead(t *testing.T, root, name string) (local, remote string) {
+	t.Helper()
+	local = testGit(t, filepath.Join(root, name), "rev-parse", "main")
+	remote = testGit(t, filepath.Join(root, "remotes", name+".git"), "rev-parse", "main")
+	return
+}
+
+// testRunCommand runs the given command with the given arguments, where the
+// first argument is the command name, and returns its output.
+func testRunCommand(cmd *clip.LeafCommand[environ], argv ...string) (stdout, stderr string, err error) {
+	var outbuf, errbuf bytes.Buffer
+	args := &clip.CommandArgs[environ]{
+		Args:        argv[1:],
+		Command:     cmd,
+		CommandName: "multirepo " + argv[0],
+		Env:         &captureEnviron{environ: newStdlibEnviron(), stdout: &outbuf, stderr: &errbuf},
+	}
+	err = cmd.RunFunc(context.Background(), args)
+	return outbuf.String(), errbuf.String(), err
+}
+
+// captureEnviron is an [environ] writing its standard output and error to the
+// given writers, which default to [io.Discard] when nil.
+type captureEnviron struct {
+	environ
+
+	// stdout is the writer to use as the standard output.
+	stdout io.Writer
+
+	// stderr is the writer to use as the standard error.
+	stderr io.Writer
+}
+
+// Stdout implements the [environ] interface.
+func (env *captureEnviron) Stdout() io.Writer {
+	if env.stdout == nil {
+		return io.Discard
+	}
+	return env.stdout
+}
+
+// Stderr implements the [environ] interface.
+func (env *captureEnviron) Stderr() io.Writer {
+	if env.stderr == nil {
+		return io.Discard
+	}
+	return env.stderr
+}
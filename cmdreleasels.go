@@ -0,0 +1,109 @@
+// cmdreleasels.go - implementation of the 'release ls' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdReleaseLs is the static 'release ls' command
+var cmdReleaseLs = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "List the releases.",
+	RunFunc:              cmdReleaseLsMain,
+}
+
+// cmdReleaseLsRunner runs the 'release ls' command.
+type cmdReleaseLsRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdReleaseLsMain is the entry point for the 'release ls' command.
+func cmdReleaseLsMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdReleaseLsRunner(args).run(args)
+}
+
+// mustNewCmdReleaseLsRunner creates a new [*cmdReleaseLsRunner].
+func mustNewCmdReleaseLsRunner(args *clip.CommandArgs[environ]) *cmdReleaseLsRunner {
+	// Initialize the default configuration.
+	c := &cmdReleaseLsRunner{
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 0
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdReleaseLsRunner) run(args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo release ls: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Read the releases
+	releases, err := listReleases(args.Env, dd)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo release ls: %s\n", err)
+		return err
+	}
+	if len(releases) <= 0 {
+		mustFprintf(args.Env.Stderr(), "multirepo release ls: no releases\n")
+		return nil
+	}
+
+	// Print the releases, newest first
+	tw := tabwriter.NewWriter(args.Env.Stdout(), 0, 8, 2, ' ', 0)
+	mustFprintf(tw, "VERSION\tCREATED\tREPOS\tMESSAGE\n")
+	for _, rel := range releases {
+		subject, _, _ := strings.Cut(rel.Message, "\n")
+		mustFprintf(tw, "%s\t%s\t%d\t%s\n", rel.Version,
+			rel.Created.Local().Format(time.DateTime), len(rel.Repos), subject)
+	}
+	assert.NotError(tw.Flush())
+	return nil
+}
@@ -0,0 +1,144 @@
+// cmdreleaseshow.go - implementation of the 'release show' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdReleaseShow is the static 'release show' command
+var cmdReleaseShow = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Show a release.",
+	RunFunc:              cmdReleaseShowMain,
+}
+
+// cmdReleaseShowRunner runs the 'release show' command.
+type cmdReleaseShowRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Version is the version of the release to show.
+	Version string
+}
+
+// --- entry & setup ---
+
+// cmdReleaseShowMain is the entry point for the 'release show' command.
+func cmdReleaseShowMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdReleaseShowRunner(args).run(ctx, args)
+}
+
+// mustNewCmdReleaseShowRunner creates a new [*cmdReleaseShowRunner].
+func mustNewCmdReleaseShowRunner(args *clip.CommandArgs[environ]) *cmdReleaseShowRunner {
+	// Initialize the default configuration.
+	c := &cmdReleaseShowRunner{
+		LockTimeout: -1,
+		Version:     "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<version>"
+	fset.MinPositionalArgs = 1
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Make sure the version is valid.
+	c.Version = fset.Args()[0]
+	if err := validateReleaseVersion(c.Version); err != nil {
+		mustFprintf(args.Env.Stderr(), "%s: %s\n", args.CommandName, err)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdReleaseShowRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo release show: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Read the release
+	rel, err := readRelease(args.Env, dd, c.Version)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo release show: %s\n", err)
+		return err
+	}
+
+	// Print the release along with the tagged commits, noting the
+	// repositories where the tag now points to a different commit
+	signed := ""
+	if rel.Signed {
+		signed = ", signed"
+	}
+	mustFprintf(args.Env.Stdout(), "release %s (created %s%s)\n\n",
+		rel.Version, rel.Created.Local().Format(time.DateTime), signed)
+	for _, line := range strings.Split(rel.Message, "\n") {
+		mustFprintf(args.Env.Stdout(), "    %s\n", line)
+	}
+	mustFprintf(args.Env.Stdout(), "\n")
+	tw := tabwriter.NewWriter(args.Env.Stdout(), 0, 8, 2, ' ', 0)
+	mustFprintf(tw, "REPO\tCOMMIT\tTAG\n")
+	for _, repo := range slices.Sorted(maps.Keys(rel.Repos)) {
+		commit := rel.Repos[repo]
+		state := "missing"
+		tagged, err := dd.queryRepoGit(ctx, args.Env, repo, "rev-parse", "-q", "--verify", "refs/tags/"+rel.Version+"^{commit}")
+		switch {
+		case err == nil && tagged == commit:
+			state = "ok"
+		case err == nil:
+			state = "moved"
+		}
+		mustFprintf(tw, "%s\t%s\t%s\n", repo, commit, state)
+	}
+	assert.NotError(tw.Flush())
+	return nil
+}
@@ -0,0 +1,273 @@
+// cmdreleasetag.go - implementation of the 'release tag' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdReleaseTag is the static 'release tag' command
+var cmdReleaseTag = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Tag all the repositories with the same version.",
+	RunFunc:              cmdReleaseTagMain,
+}
+
+// cmdReleaseTagRunner runs the 'release tag' command.
+type cmdReleaseTagRunner struct {
+	// Branch is the branch each repository must be on (empty means
+	// the default branch of the `origin` remote).
+	Branch string
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Message is the message of the annotated tags (empty means using a default message).
+	Message string
+
+	// Sign indicates whether to sign the tags.
+	Sign bool
+
+	// Version is the version, which is also the tag name.
+	Version string
+}
+
+// --- entry & setup ---
+
+// cmdReleaseTagMain is the entry point for the 'release tag' command.
+func cmdReleaseTagMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdReleaseTagRunner(args).run(ctx, args)
+}
+
+// mustNewCmdReleaseTagRunner creates a new [*cmdReleaseTagRunner].
+func mustNewCmdReleaseTagRunner(args *clip.CommandArgs[environ]) *cmdReleaseTagRunner {
+	// Initialize the default configuration.
+	c := &cmdReleaseTagRunner{
+		Branch:      "",
+		LockTimeout: -1,
+		Message:     "",
+		Sign:        false,
+		Version:     "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<version>"
+	fset.MinPositionalArgs = 1
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-b` flag.
+	fset.StringVar(&c.Branch, "branch", 'b', "Require each repository to be on the given branch.")
+
+	// Add the `-m` flag.
+	fset.StringVar(&c.Message, "message", 'm', "Use the given tag message.")
+
+	// Add the `-s` flag.
+	fset.BoolVar(&c.Sign, "sign", 's', "Create GPG-signed tags.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Make sure the version is valid.
+	c.Version = fset.Args()[0]
+	if err := validateReleaseVersion(c.Version); err != nil {
+		mustFprintf(args.Env.Stderr(), "%s: %s\n", args.CommandName, err)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	if c.Message == "" {
+		c.Message = "Release " + c.Version
+	}
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdReleaseTagRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	if err := c.tag(ctx, args.Env); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo release tag: %s\n", err)
+		return err
+	}
+	return nil
+}
+
+// tag cuts the release while holding the lock.
+func (c *cmdReleaseTagRunner) tag(ctx context.Context, env environ) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(env)
+	unlock, err := dd.lock(env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Read the configuration file and make sure the release does not exist
+	config, err := readConfig(env, dd.configFilePath())
+	if err != nil {
+		return err
+	}
+	exists, err := env.FileExists(dd.releaseFilePath(c.Version))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the %s release already exists", c.Version)
+	}
+
+	// Make sure we can tag each repository before tagging any, reporting
+	// all the problems at once, such that the user can fix all of them
+	rel := &release{
+		Version: c.Version,
+		Created: time.Now().UTC(),
+		Message: c.Message,
+		Signed:  c.Sign,
+		Repos:   map[string]string{},
+	}
+	errlist := []error{}
+	for _, repo := range config.SelectedRepos() {
+		commit, err := c.validate(ctx, env, dd, repo)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", repo, err)
+			mustFprintf(env.Stderr(), "multirepo release tag: %s\n", err)
+			errlist = append(errlist, err)
+			continue
+		}
+		rel.Repos[repo] = commit
+	}
+	if len(errlist) > 0 {
+		return errors.New("not tagging any repository")
+	}
+	if len(rel.Repos) <= 0 {
+		return errors.New("no repositories to tag")
+	}
+
+	// Create the tags and, when this fails, delete the tags we have already
+	// created, such that either all the repositories are tagged or none is
+	repos := slices.Sorted(maps.Keys(rel.Repos))
+	for idx, repo := range repos {
+		if err := c.createTag(ctx, env, dd, repo, rel.Repos[repo]); err != nil {
+			errlist := []error{fmt.Errorf("%s: %w", repo, err)}
+			for _, done := range repos[:idx] {
+				if err := dd.runRepoGit(ctx, env, done, "tag", "-d", c.Version); err != nil {
+					errlist = append(errlist, fmt.Errorf("%s: cannot delete the tag: %w", done, err))
+				}
+			}
+			return errors.Join(errlist...)
+		}
+	}
+
+	// Record the release
+	if err := writeRelease(env, dd, rel); err != nil {
+		return err
+	}
+	if err := dd.commitRelease(ctx, env, journalArgv(env), rel); err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		mustFprintf(env.Stdout(), "%s %s\n", repo, rel.Repos[repo])
+	}
+	return nil
+}
+
+// validate makes sure that the given repository is clean, is on the expected
+// branch, and does not contain the tag, returning the commit to tag.
+func (c *cmdReleaseTagRunner) validate(ctx context.Context, env environ, dd dotDir, repo string) (string, error) {
+	// Make sure the repository is clean, ignoring the untracked files
+	status, err := dd.queryRepoGit(ctx, env, repo, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return "", err
+	}
+	if status != "" {
+		return "", errors.New("the repository contains uncommitted changes")
+	}
+
+	// Make sure the repository is on the expected branch
+	expected := c.Branch
+	if expected == "" {
+		expected = c.defaultBranch(ctx, env, dd, repo)
+		if expected == "" {
+			return "", errors.New("cannot determine the default branch (hint: use `-b BRANCH`)")
+		}
+	}
+	if current := dd.currentBranch(ctx, env, repo); current != expected {
+		return "", fmt.Errorf("not on the %s branch", expected)
+	}
+
+	// Make sure the tag does not exist
+	if _, err := dd.queryRepoGit(ctx, env, repo, "show-ref", "--verify", "--quiet", "refs/tags/"+c.Version); err == nil {
+		return "", fmt.Errorf("the %s tag already exists", c.Version)
+	}
+
+	// Obtain the commit to tag
+	return dd.queryRepoGit(ctx, env, repo, "rev-parse", "--verify", "HEAD^{commit}")
+}
+
+// defaultBranch returns the default branch of the `origin` remote, using
+// `refs/remotes/origin/HEAD` when it exists (e.g., after `git clone`) and
+// otherwise asking the remote with `git ls-remote --symref`. It returns an
+// empty string when both fail.
+func (c *cmdReleaseTagRunner) defaultBranch(ctx context.Context, env environ, dd dotDir, repo string) string {
+	head, err := dd.probeRepoGit(ctx, env, repo, "symbolic-ref", "--short", "-q", "refs/remotes/origin/HEAD")
+	if err == nil && head != "" {
+		return strings.TrimPrefix(head, "origin/")
+	}
+	output, err := dd.probeRepoGit(ctx, env, repo, "ls-remote", "--symref", "origin", "HEAD")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if ref, found := strings.CutPrefix(line, "ref: refs/heads/"); found {
+			branch, _, _ := strings.Cut(ref, "\t")
+			return branch
+		}
+	}
+	return ""
+}
+
+// createTag creates the annotated or signed tag pointing to the given commit.
+func (c *cmdReleaseTagRunner) createTag(ctx context.Context, env environ, dd dotDir, repo, commit string) error {
+	kind := "-a"
+	if c.Sign {
+		kind = "-s"
+	}
+	return dd.runRepoGit(ctx, env, repo, "tag", kind, "-m", c.Message, c.Version, commit)
+}
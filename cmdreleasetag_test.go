@@ -0,0 +1,73 @@
+// cmdreleasetag_test.go - Tests for the 'release tag' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReleaseTag(t *testing.T) {
+	t.Run("default branch from the remote", func(t *testing.T) {
+		// The repositories are not clones, hence `refs/remotes/origin/HEAD`
+		// does not exist and we must ask the remote instead
+		root := newTestMultirepo(t, "r1", "r2")
+
+		stdout, stderr, err := testRunCommand(cmdReleaseTag, "release tag", "v1.0.0")
+		if err != nil {
+			t.Fatalf("release tag failed: %s: %s", err, stderr)
+		}
+		for _, name := range []string{"r1", "r2"} {
+			commit := testGit(t, filepath.Join(root, name), "rev-parse", "v1.0.0^{commit}")
+			if !strings.Contains(stdout, name+" "+commit) {
+				t.Fatalf("unexpected stdout: %q", stdout)
+			}
+		}
+	})
+
+	t.Run("wrong branch refuses every tag", func(t *testing.T) {
+		root := newTestMultirepo(t, "r1", "r2")
+		testGit(t, filepath.Join(root, "r2"), "checkout", "-q", "-b", "topic")
+
+		_, stderr, err := testRunCommand(cmdReleaseTag, "release tag", "v1.0.0")
+		if err == nil {
+			t.Fatal("expected release tag to fail")
+		}
+		if !strings.Contains(stderr, "r2: not on the main branch") {
+			t.Fatalf("unexpected stderr: %q", stderr)
+		}
+		if tags := testGit(t, filepath.Join(root, "r1"), "tag", "-l"); tags != "" {
+			t.Fatalf("expected r1 not to be tagged, got %q", tags)
+		}
+	})
+
+	t.Run("partial failure deletes the created tags", func(t *testing.T) {
+		// Make creating the tag in r2 fail by holding the lock of its ref
+		root := newTestMultirepo(t, "r1", "r2")
+		lock := filepath.Join(root, "r2", ".git", "refs", "tags", "v1.0.0.lock")
+		if err := os.WriteFile(lock, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, stderr, err := testRunCommand(cmdReleaseTag, "release tag", "-b", "main", "v1.0.0")
+		if err == nil {
+			t.Fatal("expected release tag to fail")
+		}
+		if !strings.Contains(stderr, "r2: ") {
+			t.Fatalf("unexpected stderr: %q", stderr)
+		}
+		if tags := testGit(t, filepath.Join(root, "r1"), "tag", "-l"); tags != "" {
+			t.Fatalf("expected the r1 tag to be deleted, got %q", tags)
+		}
+		exists, err := newStdlibEnviron().FileExists(dotDir(dotDirName).releaseFilePath("v1.0.0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Fatal("expected the release not to be recorded")
+		}
+	})
+}
@@ -0,0 +1,370 @@
+// cmdreplace.go - implementation of the replace command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdReplace is the static replace command
+var cmdReplace = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Search and replace a regular expression in all the repositories.",
+	RunFunc:              cmdReplaceMain,
+}
+
+// cmdReplaceRunner runs the replace command.
+type cmdReplaceRunner struct {
+	// Branch is the branch to create in each modified repository (empty
+	// means using the current branch).
+	Branch string
+
+	// Glob only selects the files matching the given pattern (empty means all files).
+	Glob string
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Message is the message to commit the changes with (empty means not committing).
+	Message string
+
+	// Regexp is the regular expression to replace.
+	Regexp *regexp.Regexp
+
+	// Replacement is the replacement, which may refer to submatches (e.g., `$1`).
+	Replacement string
+
+	// Yes indicates whether to apply the changes without asking for confirmation.
+	Yes bool
+}
+
+// --- entry & setup ---
+
+// cmdReplaceMain is the entry point for the replace command.
+func cmdReplaceMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdReplaceRunner(args).run(ctx, args)
+}
+
+// mustNewCmdReplaceRunner creates a new [*cmdReplaceRunner].
+func mustNewCmdReplaceRunner(args *clip.CommandArgs[environ]) *cmdReplaceRunner {
+	// Initialize the default configuration.
+	c := &cmdReplaceRunner{
+		Branch:      "",
+		Glob:        "",
+		LockTimeout: -1,
+		Message:     "",
+		Regexp:      nil,
+		Replacement: "",
+		Yes:         false,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<regexp> <replacement>"
+	fset.MinPositionalArgs = 2
+	fset.MaxPositionalArgs = 2
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-b` flag.
+	fset.StringVar(&c.Branch, "branch", 'b', "Create the given branch in each modified repository (requires -m).")
+
+	// Add the `--glob` flag.
+	fset.StringVar(&c.Glob, "glob", 0, "Only modify the files matching the given pattern (e.g., '*.go').")
+
+	// Add the `-m` flag.
+	fset.StringVar(&c.Message, "message", 'm', "Commit the changes in each modified repository with the given message.")
+
+	// Add the `-y` flag.
+	fset.BoolVar(&c.Yes, "yes", 'y', "Apply the changes without asking for confirmation.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag, where there is nothing to confirm since
+	// we do not write anything.
+	honourDryRunFlag(args, *dryRun)
+	if *dryRun {
+		c.Yes = true
+	}
+
+	// Compile the regular expression.
+	re, err := regexp.Compile(fset.Args()[0])
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "%s: invalid regular expression: %s\n", args.CommandName, err)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	c.Regexp, c.Replacement = re, fset.Args()[1]
+
+	// Make sure the glob is valid and that we know how to commit on the branch.
+	if _, err := path.Match(c.Glob, ""); err != nil {
+		mustFprintf(args.Env.Stderr(), "%s: invalid --glob value: %q\n", args.CommandName, c.Glob)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	if c.Branch != "" && c.Message == "" {
+		mustFprintf(args.Env.Stderr(), "%s: -b requires -m\n", args.CommandName)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+
+	return c
+}
+
+// --- execution ---
+
+// replaceEdit is an edit of a file computed by the replace command.
+type replaceEdit struct {
+	// Path is the file path relative to the repository using forward slashes.
+	Path string
+
+	// Old is the original content.
+	Old string
+
+	// New is the modified content.
+	New string
+}
+
+// replaceRepo contains the edits of a repository.
+type replaceRepo struct {
+	// Name is the repository name.
+	Name string
+
+	// Edits contains the edits.
+	Edits []replaceEdit
+}
+
+func (c *cmdReplaceRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Read the repositories to modify
+	dd := defaultDotDir(args.Env)
+	config, err := readConfigSnapshot(args.Env, dd, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo replace: %s\n", err)
+		return err
+	}
+
+	// Compute the edits of each repository
+	repos := []replaceRepo{}
+	files := 0
+	for _, name := range config.SelectedRepos() {
+		edits, err := c.computeEdits(ctx, args.Env, dd, name)
+		if err != nil {
+			mustFprintf(args.Env.Stderr(), "multirepo replace: %s: %s\n", name, err)
+			return err
+		}
+		if len(edits) > 0 {
+			repos = append(repos, replaceRepo{Name: name, Edits: edits})
+			files += len(edits)
+		}
+	}
+	if len(repos) <= 0 {
+		mustFprintf(args.Env.Stderr(), "multirepo replace: no matches\n")
+		return nil
+	}
+
+	// Show the preview grouped by repository
+	header := newNilSafeLipglossStyle()
+	for idx, repo := range repos {
+		if idx > 0 {
+			mustFprintf(args.Env.Stdout(), "\n")
+		}
+		mustFprintf(args.Env.Stdout(), "%s\n", header.Renderf("==> %s (%d files)", repo.Name, len(repo.Edits)))
+		for _, edit := range repo.Edits {
+			name := path.Join(repo.Name, edit.Path)
+			mustFprintf(args.Env.Stdout(), "%s", unifiedDiff("a/"+name, "b/"+name, edit.Old, edit.New))
+		}
+	}
+	mustFprintf(args.Env.Stdout(), "\n")
+
+	// Make sure the branch does not exist before modifying anything
+	if c.Branch != "" {
+		for _, repo := range repos {
+			if dd.branchExists(ctx, args.Env, repo.Name, c.Branch) {
+				err := fmt.Errorf("%s: the %s branch already exists", repo.Name, c.Branch)
+				mustFprintf(args.Env.Stderr(), "multirepo replace: %s\n", err)
+				return err
+			}
+		}
+	}
+
+	// Make sure we do not commit the uncommitted changes to the files we modify
+	if c.Message != "" {
+		for _, repo := range repos {
+			if err := c.checkClean(ctx, args.Env, dd, repo); err != nil {
+				err = fmt.Errorf("%s: %w", repo.Name, err)
+				mustFprintf(args.Env.Stderr(), "multirepo replace: %s\n", err)
+				return err
+			}
+		}
+	}
+
+	// Ask for confirmation unless told otherwise
+	if !c.Yes {
+		mustFprintf(args.Env.Stderr(), "Apply the changes to %d files in %d repositories? [y/N] ", files, len(repos))
+		answer, _ := readLineUnbuffered(args.Env.Stdin())
+		if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			mustFprintf(args.Env.Stderr(), "multirepo replace: not applying the changes\n")
+			return nil
+		}
+	}
+
+	// Apply the changes, continuing with the next repository on failure
+	errlist := []error{}
+	for _, repo := range repos {
+		if err := c.apply(ctx, args.Env, dd, repo); err != nil {
+			err = fmt.Errorf("%s: %w", repo.Name, err)
+			mustFprintf(args.Env.Stderr(), "multirepo replace: %s\n", err)
+			errlist = append(errlist, err)
+		}
+	}
+	return errors.Join(errlist...)
+}
+
+// computeEdits computes the edits of the text files tracked by the given
+// repository matching the glob, if any. We skip symbolic links, submodules,
+// and binary files (i.e., files containing NUL bytes).
+func (c *cmdReplaceRunner) computeEdits(ctx context.Context,
+	env environ, dd dotDir, repo string) ([]replaceEdit, error) {
+	// List the tracked files along with their modes
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "-s")
+	cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
+	cmd.Stdout = &stdout
+	cmd.Stderr = env.Stderr()
+	cmd.Dir = dd.repoPath(repo)
+	if err := env.RunQueryCommand(cmd); err != nil {
+		return nil, err
+	}
+
+	// Replace in each regular file matching the glob
+	edits := []replaceEdit{}
+	for _, entry := range strings.Split(stdout.String(), "\x00") {
+		// Each entry is `<mode> <object> <stage>\t<path>`
+		meta, name, found := strings.Cut(entry, "\t")
+		if !found || !(strings.HasPrefix(meta, "100644 ") || strings.HasPrefix(meta, "100755 ")) {
+			continue
+		}
+		if !c.matchGlob(name) {
+			continue
+		}
+		data, err := env.ReadFile(filepath.Join(dd.repoPath(repo), filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+		old := string(data)
+		if modified := c.Regexp.ReplaceAllString(old, c.Replacement); modified != old {
+			edits = append(edits, replaceEdit{Path: name, Old: old, New: modified})
+		}
+	}
+	return edits, nil
+}
+
+// matchGlob returns whether the given slash-separated path matches the glob, where
+// we match globs without slashes against the base name (e.g., `*.go` matches
+// `cmd/main.go`) and the other ones against the whole path.
+func (c *cmdReplaceRunner) matchGlob(name string) bool {
+	switch {
+	case c.Glob == "":
+		return true
+	case strings.Contains(c.Glob, "/"):
+		matched, _ := path.Match(c.Glob, name)
+		return matched
+	default:
+		matched, _ := path.Match(c.Glob, path.Base(name))
+		return matched
+	}
+}
+
+// checkClean returns an error if the files we would modify in the given repository
+// contain uncommitted changes, which committing with `-m` would include.
+func (c *cmdReplaceRunner) checkClean(ctx context.Context, env environ, dd dotDir, repo replaceRepo) error {
+	argv := []string{"status", "--porcelain", "--"}
+	for _, edit := range repo.Edits {
+		argv = append(argv, edit.Path)
+	}
+	status, err := dd.queryRepoGit(ctx, env, repo.Name, argv...)
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		return errors.New("the files to modify contain uncommitted changes (hint: commit or stash them first)")
+	}
+	return nil
+}
+
+// apply applies the edits of the given repository, after creating the branch,
+// if needed, and then commits the modified files, if needed. We refuse to
+// overwrite the files that changed after we computed the edits.
+func (c *cmdReplaceRunner) apply(ctx context.Context, env environ, dd dotDir, repo replaceRepo) error {
+	// Create and switch to the branch
+	if c.Branch != "" {
+		if err := dd.runRepoGit(ctx, env, repo.Name, "switch", "-q", "-c", c.Branch); err != nil {
+			return err
+		}
+	}
+
+	// Write the modified files
+	paths := []string{}
+	for _, edit := range repo.Edits {
+		filename := filepath.Join(dd.repoPath(repo.Name), filepath.FromSlash(edit.Path))
+		data, err := env.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if string(data) != edit.Old {
+			return fmt.Errorf("%s: the file changed after computing the edits", edit.Path)
+		}
+		if err := env.WriteFile(filename, []byte(edit.New), 0644); err != nil {
+			return err
+		}
+		paths = append(paths, edit.Path)
+	}
+
+	// Commit only the modified files
+	if c.Message == "" {
+		return nil
+	}
+	argv := append([]string{"commit", "-q", "-m", c.Message, "--"}, paths...)
+	return dd.runRepoGit(ctx, env, repo.Name, argv...)
+}
@@ -10,11 +10,11 @@ import (
 	"math"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/clip/pkg/nflag"
-	"github.com/kballard/go-shellquote"
 )
 
 // cmdRepoAdd is the static 'repo add' command
@@ -25,14 +25,11 @@ var cmdRepoAdd = &clip.LeafCommand[environ]{
 
 // cmdRepoAddRunner runs the 'repo add' command.
 type cmdRepoAddRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
 	// Repo is the repository directory name to add.
 	Repos []string
-
-	// Style is the nil-safe lipgloss style to use.
-	Style *nilSafeLipglossStyle
-
-	// XWriter is the writer used to log executed commands.
-	XWriter io.Writer
 }
 
 // --- entry & setup ---
@@ -46,9 +43,8 @@ func cmdRepoAddMain(ctx context.Context, args *clip.CommandArgs[environ]) error
 func mustNewCmdRepoAddRunner(args *clip.CommandArgs[environ]) *cmdRepoAddRunner {
 	// Initialize the default configuration.
 	c := &cmdRepoAddRunner{
-		Repos:   []string{},
-		Style:   nil,
-		XWriter: io.Discard,
+		LockTimeout: -1,
+		Repos:       []string{},
 	}
 
 	// Create empty command line parser.
@@ -64,14 +60,29 @@ func mustNewCmdRepoAddRunner(args *clip.CommandArgs[environ]) *cmdRepoAddRunner
 	// Add the `-x` flag.
 	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
 
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
 	// Parse the command line arguments.
 	assert.NotError(fset.Parse(args.Args))
 
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
 	// Honour the `-x` flag.
-	if *xflag {
-		c.XWriter = args.Env.Stderr()
-		c.Style = newNilSafeLipglossStyle()
-	}
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
 
 	// Add the repo to add to the multirepo index.
 	c.Repos = fset.Args()
@@ -83,8 +94,8 @@ func mustNewCmdRepoAddRunner(args *clip.CommandArgs[environ]) *cmdRepoAddRunner
 
 func (c *cmdRepoAddRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
 	// Lock the multirepo dir
-	dd := defaultDotDir()
-	unlock, err := dd.lock(args.Env)
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
 	if err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo repo add: %s\n", err)
 		return err
@@ -97,11 +108,20 @@ func (c *cmdRepoAddRunner) run(ctx context.Context, args *clip.CommandArgs[envir
 		mustFprintf(args.Env.Stderr(), "multirepo repo add: %s\n", err)
 		return err
 	}
+	before := config.Clone()
 
 	// Iterate over the repositories
-	for _, repo := range c.Repos {
+	for _, path := range c.Repos {
+		// Obtain the name, which is relative to the root rather than to the
+		// current directory, which differ in nested `multirepo` invocations
+		repo, err := dd.repoNameFromPath(args.Env, path)
+		if err != nil {
+			mustFprintf(args.Env.Stderr(), "multirepo repo add: %s\n", err)
+			return err
+		}
+
 		// Obtain the URL
-		URL, err := c.getrepourl(ctx, args.Env, repo)
+		URL, err := c.getrepourl(ctx, args.Env, dd, repo)
 		if err != nil {
 			mustFprintf(args.Env.Stderr(), "multirepo repo add: %s\n", err)
 			return err
@@ -112,7 +132,7 @@ func (c *cmdRepoAddRunner) run(ctx context.Context, args *clip.CommandArgs[envir
 	}
 
 	// Write the configuration file back to disk
-	if err := config.WriteFile(args.Env, dd.configFilePath()); err != nil {
+	if err := dd.saveConfig(ctx, args.Env, journalArgv(args.Env), before, config); err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo repo add: %s\n", err)
 		return err
 	}
@@ -121,24 +141,17 @@ func (c *cmdRepoAddRunner) run(ctx context.Context, args *clip.CommandArgs[envir
 }
 
 // getrepourl obtains the repository URL.
-func (c *cmdRepoAddRunner) getrepourl(ctx context.Context, env environ, repo string) (string, error) {
+func (c *cmdRepoAddRunner) getrepourl(ctx context.Context, env environ, dd dotDir, repo string) (string, error) {
 	// Create the subcommand to execute.
 	var captured strings.Builder
 	cmd := exec.CommandContext(ctx, "git", "config", "--get", "remote.origin.url")
 	cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
 	cmd.Stdout = &captured
 	cmd.Stderr = env.Stderr()
-	cmd.Dir = repo
-
-	// Log that we're executing the command.
-	//
-	// Add a newline before each entry so that it stands out when
-	// skimming the terminal. Note that we cannot make `-x` the
-	// default, since it would be quite annoying when reading diffs
-	mustFprintf(c.XWriter, "%s\n", c.Style.Renderf("+ (cd %s && %s)", shellquote.Join(repo), shellquote.Join(cmd.Args...)))
+	cmd.Dir = dd.repoPath(repo)
 
-	// Execute the command
-	if err := env.RunCommand(cmd); err != nil {
+	// Execute the command, which only queries the repository
+	if err := env.RunQueryCommand(cmd); err != nil {
 		return "", err
 	}
 
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"maps"
 	"slices"
+	"time"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
@@ -21,7 +22,10 @@ var cmdRepoLs = &clip.LeafCommand[environ]{
 }
 
 // cmdRepoLsRunner runs the 'repo ls' command.
-type cmdRepoLsRunner struct{}
+type cmdRepoLsRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
 
 // --- entry & setup ---
 
@@ -33,7 +37,9 @@ func cmdRepoLsMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
 // mustNewCmdRepoLsRunner creates a new [*cmdRepoLsRunner].
 func mustNewCmdRepoLsRunner(args *clip.CommandArgs[environ]) *cmdRepoLsRunner {
 	// initialize the default configuration.
-	c := &cmdRepoLsRunner{}
+	c := &cmdRepoLsRunner{
+		LockTimeout: -1,
+	}
 
 	// Create empty command line parser.
 	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
@@ -45,9 +51,27 @@ func mustNewCmdRepoLsRunner(args *clip.CommandArgs[environ]) *cmdRepoLsRunner {
 	// Add the `-h, --help` flag.
 	fset.AutoHelp("help", 'h', "Show this help message and exit.")
 
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
 	// Parse the command line arguments.
 	assert.NotError(fset.Parse(args.Args))
 
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
 	return c
 }
 
@@ -55,8 +79,8 @@ func mustNewCmdRepoLsRunner(args *clip.CommandArgs[environ]) *cmdRepoLsRunner {
 
 func (c *cmdRepoLsRunner) run(args *clip.CommandArgs[environ]) error {
 	// Lock the multirepo dir
-	dd := defaultDotDir()
-	unlock, err := dd.lock(args.Env)
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
 	if err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo repo ls: %s\n", err)
 		return err
@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
@@ -19,6 +20,9 @@ var cmdRepoRm = &clip.LeafCommand[environ]{
 
 // cmdRepoRmRunner runs the 'repo rm' command.
 type cmdRepoRmRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
 	// Repo is the name of the repository directory to remove.
 	Repo string
 }
@@ -27,14 +31,15 @@ type cmdRepoRmRunner struct {
 
 // cmdRepRmMain is the entry point for the 'repo rm' command.
 func cmdRepoRmMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
-	return mustNewCmdRepoRmRunner(args).run(args)
+	return mustNewCmdRepoRmRunner(args).run(ctx, args)
 }
 
 // mustNewCmdRepoRmRunner creates a new [*cmdRepoRmRunner].
 func mustNewCmdRepoRmRunner(args *clip.CommandArgs[environ]) *cmdRepoRmRunner {
 	// initialize the default configuration.
 	c := &cmdRepoRmRunner{
-		Repo: "",
+		LockTimeout: -1,
+		Repo:        "",
 	}
 
 	// Create empty command line parser.
@@ -47,9 +52,33 @@ func mustNewCmdRepoRmRunner(args *clip.CommandArgs[environ]) *cmdRepoRmRunner {
 	// Add the `-h, --help` flag.
 	fset.AutoHelp("help", 'h', "Show this help message and exit.")
 
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
 	// Parse the command line arguments.
 	assert.NotError(fset.Parse(args.Args))
 
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
 	// Add the repo to remove
 	c.Repo = fset.Args()[0]
 	return c
@@ -57,10 +86,10 @@ func mustNewCmdRepoRmRunner(args *clip.CommandArgs[environ]) *cmdRepoRmRunner {
 
 // --- execution ---
 
-func (c *cmdRepoRmRunner) run(args *clip.CommandArgs[environ]) error {
+func (c *cmdRepoRmRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
 	// Lock the multirepo dir
-	dd := defaultDotDir()
-	unlock, err := dd.lock(args.Env)
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
 	if err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo repo rm: %s\n", err)
 		return err
@@ -73,12 +102,13 @@ func (c *cmdRepoRmRunner) run(args *clip.CommandArgs[environ]) error {
 		mustFprintf(args.Env.Stderr(), "multirepo repo rm: %s\n", err)
 		return err
 	}
+	before := config.Clone()
 
 	// Remove from the configuration
 	delete(config.Repos, c.Repo)
 
 	// Write the configuration file back to disk
-	if err := config.WriteFile(args.Env, dd.configFilePath()); err != nil {
+	if err := dd.saveConfig(ctx, args.Env, journalArgv(args.Env), before, config); err != nil {
 		mustFprintf(args.Env.Stderr(), "multirepo repo rm: %s\n", err)
 		return err
 	}
@@ -0,0 +1,164 @@
+// cmdtopicfinish.go - implementation of the 'topic finish' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdTopicFinish is the static 'topic finish' command
+var cmdTopicFinish = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Merge and delete a topic branch in each repository of the topic.",
+	RunFunc:              cmdTopicFinishMain,
+}
+
+// cmdTopicFinishRunner runs the 'topic finish' command.
+type cmdTopicFinishRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Name is the name of the topic to finish.
+	Name string
+
+	// NoMerge indicates whether to delete the topic branches without merging them.
+	NoMerge bool
+}
+
+// --- entry & setup ---
+
+// cmdTopicFinishMain is the entry point for the 'topic finish' command.
+func cmdTopicFinishMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdTopicFinishRunner(args).run(ctx, args)
+}
+
+// mustNewCmdTopicFinishRunner creates a new [*cmdTopicFinishRunner].
+func mustNewCmdTopicFinishRunner(args *clip.CommandArgs[environ]) *cmdTopicFinishRunner {
+	// Initialize the default configuration.
+	c := &cmdTopicFinishRunner{
+		LockTimeout: -1,
+		Name:        "",
+		NoMerge:     false,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<name>"
+	fset.MinPositionalArgs = 1
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `--no-merge` flag.
+	fset.BoolVar(&c.NoMerge, "no-merge", 0, "Delete the topic branches without merging them.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the topic name.
+	c.Name = fset.Args()[0]
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdTopicFinishRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	if err := c.finish(ctx, args.Env); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic finish: %s\n", err)
+		return err
+	}
+	return nil
+}
+
+// finish finishes the topic while holding the lock.
+func (c *cmdTopicFinishRunner) finish(ctx context.Context, env environ) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(env)
+	unlock, err := dd.lock(env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Read the topics
+	topics, err := readTopics(env, dd.topicsFilePath())
+	if err != nil {
+		return err
+	}
+	tp, err := topics.lookupTopic(c.Name)
+	if err != nil {
+		return err
+	}
+
+	// Finish the topic in each repository, stopping at the first failure (e.g., a
+	// merge conflict) and removing the repositories where we succeeded from the
+	// topic, such that the user can fix the issue and finish the topic again
+	var failure error
+	for _, repo := range slices.Sorted(maps.Keys(tp.Repos)) {
+		if err := c.finishRepo(ctx, env, dd, repo, tp.Repos[repo]); err != nil {
+			failure = fmt.Errorf("%s: %w", repo, err)
+			break
+		}
+		delete(tp.Repos, repo)
+	}
+
+	// Forget about the topic once we finished it in all the repositories
+	if len(tp.Repos) <= 0 {
+		delete(topics.Topics, c.Name)
+	}
+	if err := writeTopics(env, dd.topicsFilePath(), topics); err != nil {
+		return err
+	}
+	return failure
+}
+
+// finishRepo switches to the given base branch, merges the topic branch,
+// unless we should not merge, and deletes the topic branch.
+func (c *cmdTopicFinishRunner) finishRepo(ctx context.Context,
+	env environ, dd dotDir, repo, base string) error {
+	if err := dd.runRepoGit(ctx, env, repo, "switch", "-q", base); err != nil {
+		return err
+	}
+	if c.NoMerge {
+		return dd.runRepoGit(ctx, env, repo, "branch", "-q", "-D", c.Name)
+	}
+	if err := dd.runRepoGit(ctx, env, repo, "merge", "-q", "--no-edit", c.Name); err != nil {
+		return err
+	}
+	return dd.runRepoGit(ctx, env, repo, "branch", "-q", "-d", c.Name)
+}
@@ -0,0 +1,174 @@
+// cmdtopicstart.go - implementation of the 'topic start' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdTopicStart is the static 'topic start' command
+var cmdTopicStart = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Start a topic branch in several repositories.",
+	RunFunc:              cmdTopicStartMain,
+}
+
+// cmdTopicStartRunner runs the 'topic start' command.
+type cmdTopicStartRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Name is the name of the topic and of the branches.
+	Name string
+
+	// Repos contains the repositories involved in the topic (empty
+	// means all the repositories not excluded by the settings).
+	Repos []string
+}
+
+// --- entry & setup ---
+
+// cmdTopicStartMain is the entry point for the 'topic start' command.
+func cmdTopicStartMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdTopicStartRunner(args).run(ctx, args)
+}
+
+// mustNewCmdTopicStartRunner creates a new [*cmdTopicStartRunner].
+func mustNewCmdTopicStartRunner(args *clip.CommandArgs[environ]) *cmdTopicStartRunner {
+	// Initialize the default configuration.
+	c := &cmdTopicStartRunner{
+		LockTimeout: -1,
+		Name:        "",
+		Repos:       []string{},
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<name> [repo...]"
+	fset.MinPositionalArgs = 1
+	fset.MaxPositionalArgs = math.MaxInt
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the topic name and the repositories.
+	c.Name, c.Repos = fset.Args()[0], fset.Args()[1:]
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdTopicStartRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	if err := c.start(ctx, args.Env); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic start: %s\n", err)
+		return err
+	}
+	return nil
+}
+
+// start starts the topic while holding the lock.
+func (c *cmdTopicStartRunner) start(ctx context.Context, env environ) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(env)
+	unlock, err := dd.lock(env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Read the configuration file and the topics
+	config, err := readConfig(env, dd.configFilePath())
+	if err != nil {
+		return err
+	}
+	topics, err := readTopics(env, dd.topicsFilePath())
+	if err != nil {
+		return err
+	}
+	if _, found := topics.Topics[c.Name]; found {
+		return fmt.Errorf("the %s topic already exists", c.Name)
+	}
+
+	// Determine the repositories involved in the topic
+	repos := c.Repos
+	if len(repos) <= 0 {
+		repos = config.SelectedRepos()
+	}
+	for _, repo := range repos {
+		if _, found := config.Repos[repo]; !found {
+			return fmt.Errorf("no such repository: %s", repo)
+		}
+	}
+	slices.Sort(repos)
+	repos = slices.Compact(repos)
+
+	// Make sure we can create the branch in each repository before creating any
+	bases := map[string]string{}
+	for _, repo := range repos {
+		base := dd.currentBranch(ctx, env, repo)
+		if base == "" {
+			return fmt.Errorf("%s: not on a branch", repo)
+		}
+		if dd.branchExists(ctx, env, repo, c.Name) {
+			return fmt.Errorf("%s: the %s branch already exists", repo, c.Name)
+		}
+		bases[repo] = base
+	}
+
+	// Create the branches, stopping at the first failure and only recording
+	// the repositories where we succeeded, such that we can finish the topic
+	var errlist []error
+	tp := &topic{Created: time.Now().UTC(), Repos: map[string]string{}}
+	for _, repo := range repos {
+		if err := dd.runRepoGit(ctx, env, repo, "switch", "-q", "-c", c.Name); err != nil {
+			errlist = append(errlist, fmt.Errorf("%s: %w", repo, err))
+			break
+		}
+		tp.Repos[repo] = bases[repo]
+	}
+	if len(tp.Repos) > 0 {
+		topics.Topics[c.Name] = tp
+		if err := writeTopics(env, dd.topicsFilePath(), topics); err != nil {
+			errlist = append(errlist, err)
+		}
+	}
+	return errors.Join(errlist...)
+}
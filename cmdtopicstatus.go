@@ -0,0 +1,158 @@
+// cmdtopicstatus.go - implementation of the 'topic status' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdTopicStatus is the static 'topic status' command
+var cmdTopicStatus = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Show the status of the topic branches.",
+	RunFunc:              cmdTopicStatusMain,
+}
+
+// cmdTopicStatusRunner runs the 'topic status' command.
+type cmdTopicStatusRunner struct {
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Name is the name of the topic to show (empty means all the topics).
+	Name string
+}
+
+// --- entry & setup ---
+
+// cmdTopicStatusMain is the entry point for the 'topic status' command.
+func cmdTopicStatusMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdTopicStatusRunner(args).run(ctx, args)
+}
+
+// mustNewCmdTopicStatusRunner creates a new [*cmdTopicStatusRunner].
+func mustNewCmdTopicStatusRunner(args *clip.CommandArgs[environ]) *cmdTopicStatusRunner {
+	// Initialize the default configuration.
+	c := &cmdTopicStatusRunner{
+		LockTimeout: -1,
+		Name:        "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "[name]"
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the topic name.
+	if len(fset.Args()) > 0 {
+		c.Name = fset.Args()[0]
+	}
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdTopicStatusRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic status: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Read the topics
+	topics, err := readTopics(args.Env, dd.topicsFilePath())
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic status: %s\n", err)
+		return err
+	}
+
+	// Select the topics to show
+	names := slices.Sorted(maps.Keys(topics.Topics))
+	if c.Name != "" {
+		if _, err := topics.lookupTopic(c.Name); err != nil {
+			mustFprintf(args.Env.Stderr(), "multirepo topic status: %s\n", err)
+			return err
+		}
+		names = []string{c.Name}
+	}
+	if len(names) <= 0 {
+		mustFprintf(args.Env.Stderr(), "multirepo topic status: no topics\n")
+		return nil
+	}
+
+	// Show each topic
+	for idx, name := range names {
+		if idx > 0 {
+			mustFprintf(args.Env.Stdout(), "\n")
+		}
+		c.show(ctx, args.Env, dd, name, topics.Topics[name])
+	}
+	return nil
+}
+
+// show shows the status of the given topic, including, for each repository,
+// the base branch, how many commits the topic branch is ahead of and behind
+// the base branch, and whether the repository is on the topic branch.
+func (c *cmdTopicStatusRunner) show(ctx context.Context, env environ, dd dotDir, name string, tp *topic) {
+	mustFprintf(env.Stdout(), "topic %s (started %s)\n\n", name, tp.Created.Local().Format(time.DateTime))
+	tw := tabwriter.NewWriter(env.Stdout(), 0, 8, 2, ' ', 0)
+	mustFprintf(tw, "REPO\tBASE\tAHEAD\tBEHIND\tCURRENT\n")
+	for _, repo := range slices.Sorted(maps.Keys(tp.Repos)) {
+		base := tp.Repos[repo]
+		ahead, behind := "?", "?"
+		counts, err := dd.queryRepoGit(ctx, env, repo, "rev-list", "--left-right", "--count", base+"..."+name)
+		if fields := strings.Fields(counts); err == nil && len(fields) == 2 {
+			behind, ahead = fields[0], fields[1]
+		}
+		current := "no"
+		if dd.currentBranch(ctx, env, repo) == name {
+			current = "yes"
+		}
+		mustFprintf(tw, "%s\t%s\t%s\t%s\t%s\n", repo, base, ahead, behind, current)
+	}
+	assert.NotError(tw.Flush())
+}
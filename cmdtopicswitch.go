@@ -0,0 +1,138 @@
+// cmdtopicswitch.go - implementation of the 'topic switch' command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdTopicSwitch is the static 'topic switch' command
+var cmdTopicSwitch = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Switch to a topic branch in each repository of the topic.",
+	RunFunc:              cmdTopicSwitchMain,
+}
+
+// cmdTopicSwitchRunner runs the 'topic switch' command.
+type cmdTopicSwitchRunner struct {
+	// Base indicates whether to switch back to the base branches.
+	Base bool
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+
+	// Name is the name of the topic to switch to.
+	Name string
+}
+
+// --- entry & setup ---
+
+// cmdTopicSwitchMain is the entry point for the 'topic switch' command.
+func cmdTopicSwitchMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdTopicSwitchRunner(args).run(ctx, args)
+}
+
+// mustNewCmdTopicSwitchRunner creates a new [*cmdTopicSwitchRunner].
+func mustNewCmdTopicSwitchRunner(args *clip.CommandArgs[environ]) *cmdTopicSwitchRunner {
+	// Initialize the default configuration.
+	c := &cmdTopicSwitchRunner{
+		Base:        false,
+		LockTimeout: -1,
+		Name:        "",
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "<name>"
+	fset.MinPositionalArgs = 1
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `--base` flag.
+	fset.BoolVar(&c.Base, "base", 0, "Switch back to the base branches instead.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Add the topic name.
+	c.Name = fset.Args()[0]
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdTopicSwitchRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockShared, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic switch: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Read the topics
+	topics, err := readTopics(args.Env, dd.topicsFilePath())
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic switch: %s\n", err)
+		return err
+	}
+	tp, err := topics.lookupTopic(c.Name)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo topic switch: %s\n", err)
+		return err
+	}
+
+	// Switch in each repository, continuing on failure (e.g., when the working
+	// tree contains changes conflicting with the branch we're switching to)
+	errlist := []error{}
+	for _, repo := range slices.Sorted(maps.Keys(tp.Repos)) {
+		branch := c.Name
+		if c.Base {
+			branch = tp.Repos[repo]
+		}
+		if err := dd.runRepoGit(ctx, args.Env, repo, "switch", "-q", branch); err != nil {
+			err = fmt.Errorf("%s: %w", repo, err)
+			mustFprintf(args.Env.Stderr(), "multirepo topic switch: %s\n", err)
+			errlist = append(errlist, err)
+		}
+	}
+	return errors.Join(errlist...)
+}
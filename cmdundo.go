@@ -0,0 +1,160 @@
+// cmdundo.go - implementation of the undo command.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// cmdUndo is the static undo command
+var cmdUndo = &clip.LeafCommand[environ]{
+	BriefDescriptionText: "Revert the most recent index mutations.",
+	RunFunc:              cmdUndoMain,
+}
+
+// cmdUndoRunner runs the undo command.
+type cmdUndoRunner struct {
+	// Count is the number of mutations to revert.
+	Count string
+
+	// LockTimeout is the timeout for locking the dot directory (see [dotDir.lock]).
+	LockTimeout time.Duration
+}
+
+// --- entry & setup ---
+
+// cmdUndoMain is the entry point for the undo command.
+func cmdUndoMain(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	return mustNewCmdUndoRunner(args).run(ctx, args)
+}
+
+// mustNewCmdUndoRunner creates a new [*cmdUndoRunner].
+func mustNewCmdUndoRunner(args *clip.CommandArgs[environ]) *cmdUndoRunner {
+	// Initialize the default configuration.
+	c := &cmdUndoRunner{
+		Count:       "1",
+		LockTimeout: -1,
+	}
+
+	// Create empty command line parser.
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset.Description = args.Command.BriefDescription()
+	fset.PositionalArgumentsUsage = "[N]"
+	fset.MinPositionalArgs = 0
+	fset.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	fset.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Add the `-x` flag.
+	xflag := fset.Bool("print-commands", 'x', "Log the commands we execute.")
+
+	// Add the `--lock-timeout` and `--no-wait` flags.
+	lflags := newLockFlags(fset)
+
+	// Add the `--log-file` flag.
+	logFile := addLogFileFlag(fset)
+
+	// Add the `--dry-run` flag.
+	dryRun := addDryRunFlag(fset)
+
+	// Parse the command line arguments.
+	assert.NotError(fset.Parse(args.Args))
+
+	// Honour the `--lock-timeout` and `--no-wait` flags.
+	c.LockTimeout = lflags.mustTimeout(args)
+
+	// Honour the `--log-file` flag.
+	honourLogFileFlag(args, *logFile)
+
+	// Honour the `-x` flag.
+	honourPrintCommandsFlag(args, *xflag)
+
+	// Honour the `--dry-run` flag.
+	honourDryRunFlag(args, *dryRun)
+
+	// Set the number of mutations to revert.
+	if len(fset.Args()) > 0 {
+		c.Count = fset.Args()[0]
+	}
+
+	return c
+}
+
+// --- execution ---
+
+func (c *cmdUndoRunner) run(ctx context.Context, args *clip.CommandArgs[environ]) error {
+	// Lock the multirepo dir
+	dd := defaultDotDir(args.Env)
+	unlock, err := dd.lock(args.Env, lockExclusive, c.LockTimeout)
+	if err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo undo: %s\n", err)
+		return err
+	}
+	defer unlock()
+
+	// Revert the mutations
+	if err := c.undo(ctx, args.Env, dd); err != nil {
+		mustFprintf(args.Env.Stderr(), "multirepo undo: %s\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// undo reverts the most recent mutations.
+func (c *cmdUndoRunner) undo(ctx context.Context, env environ, dd dotDir) error {
+	// Parse the number of mutations to revert
+	count, err := strconv.Atoi(c.Count)
+	if err != nil || count <= 0 {
+		return fmt.Errorf("invalid number of mutations to revert: %s", c.Count)
+	}
+
+	// Read the configuration file
+	config, err := readConfig(env, dd.configFilePath())
+	if err != nil {
+		return err
+	}
+
+	// Read the journal
+	entries, err := readJournal(env, dd.journalFilePath())
+	if err != nil {
+		return err
+	}
+	if count > len(entries) {
+		return fmt.Errorf("cannot revert %d mutations: the journal only contains %d", count, len(entries))
+	}
+
+	// Refuse to proceed if the configuration does not match the journal
+	// since we would otherwise discard changes not recorded therein
+	if !maps.EqualFunc(config.Repos, entries[len(entries)-1].After, repoInfo.Equal) {
+		return errJournalMismatch
+	}
+
+	// Restore the repositories as they were before the oldest reverted mutation
+	// and drop the reverted mutations from the journal
+	keep := len(entries) - count
+	before := config.Clone()
+	config.Repos = maps.Clone(entries[keep].Before)
+	if config.Repos == nil {
+		config.Repos = make(map[string]repoInfo)
+	}
+	if err := config.WriteFile(env, dd.configFilePath()); err != nil {
+		return err
+	}
+	if err := writeJournal(env, dd.journalFilePath(), entries[:keep]); err != nil {
+		return err
+	}
+
+	// Commit the mutation if the manifest is tracked
+	return dd.commitManifest(ctx, env, journalArgv(env), before, config)
+}
@@ -3,21 +3,78 @@
 
 package main
 
-import "encoding/json"
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
 
 // config contains the configuration.
 type config struct {
+	// Version is the configuration schema version.
+	Version int `json:"version"`
+
 	// Repos maps repository names to their information.
 	Repos map[string]repoInfo `json:"repos"`
+
+	// Settings contains the settings defined by the shared configuration.
+	Settings configSettingsLayer `json:"settings,omitzero"`
+
+	// Effective contains the settings obtained by merging the shared
+	// configuration with the local and user-level overlays.
+	Effective configSettings `json:"-"`
+
+	// Origins maps each configuration key to the file defining it.
+	Origins map[string]string `json:"-"`
+
+	// MigratedFrom is the configuration version of the file we read, which
+	// differs from [configVersion] if we migrated the configuration.
+	MigratedFrom int `json:"-"`
+
+	// OriginalData contains the data we read before migrating.
+	OriginalData []byte `json:"-"`
 }
 
 // repoInfo contains information about a repository.
 type repoInfo struct {
 	// URL contains the scp-like URL of the repository.
 	URL string `json:"url"`
+
+	// Tags optionally contains tags used to group repositories.
+	Tags []string `json:"tags,omitempty"`
 }
 
-// readConfig reads the configuration from a file.
+// Equal returns whether two [repoInfo] are equal.
+func (ri repoInfo) Equal(other repoInfo) bool {
+	return ri.URL == other.URL && slices.Equal(ri.Tags, other.Tags)
+}
+
+// String returns the URL followed by the tags, if any.
+func (ri repoInfo) String() string {
+	if len(ri.Tags) <= 0 {
+		return ri.URL
+	}
+	return fmt.Sprintf("%s [%s]", ri.URL, strings.Join(ri.Tags, ","))
+}
+
+// newConfig creates a new, empty configuration.
+func newConfig() *config {
+	return &config{
+		Version:      configVersion,
+		Repos:        make(map[string]repoInfo),
+		MigratedFrom: configVersion,
+	}
+}
+
+// readConfig reads and validates the configuration from a file and merges
+// the local and user-level overlays into the effective settings.
+//
+// When the file uses an older configuration version, we migrate it in memory
+// to the current version, and [*config.WriteFile] will write a backup of the
+// original file before writing the migrated configuration. You MUST only invoke
+// this function when the `.multirepo` directory has been locked.
 func readConfig(env environ, filename string) (*config, error) {
 	// read the file from the disk
 	data, err := env.ReadFile(filename)
@@ -25,28 +82,96 @@ func readConfig(env environ, filename string) (*config, error) {
 		return nil, err
 	}
 
-	// attempt to parse to JSON
-	var cfg config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// possibly migrate from older versions
+	migrated, version, err := migrateConfig(filename, data)
+	if err != nil {
+		return nil, checkConfigSyntax(filename, data, err)
+	}
+
+	// parse and validate the configuration
+	cfg, err := parseConfig(filename, migrated, data)
+	if err != nil {
 		return nil, err
 	}
 
-	// ensure the map is not empty
-	if cfg.Repos == nil {
-		cfg.Repos = make(map[string]repoInfo)
+	// remember the original data to back it up when writing
+	cfg.MigratedFrom = version
+	if version != configVersion {
+		cfg.OriginalData = data
 	}
 
-	return &cfg, nil
+	// merge the overlays into the effective settings
+	if err := mergeConfigOverlays(env, filename, cfg); err != nil {
+		return nil, err
+	}
+
+	// start logging the subprocesses if the settings say so
+	honourLogFileSetting(env, cfg)
+	return cfg, nil
+}
+
+// readConfigSnapshot locks the `.multirepo` directory in shared mode, reads
+// the configuration, and releases the lock. Read-only commands that execute
+// git in each repository use this function to avoid blocking other multirepo
+// invocations while git runs.
+func readConfigSnapshot(env environ, dd dotDir, lockTimeout time.Duration) (*config, error) {
+	unlock, err := dd.lock(env, lockShared, lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return readConfig(env, dd.configFilePath())
+}
+
+// Marshal serializes the configuration to JSON.
+func (cfg *config) Marshal() []byte {
+	return append(mustMarshalIndentJSON(cfg, "", "  "), '\n')
 }
 
-// WriteFile writes the configuration to a file.
+// WriteFile writes the configuration to a file. If we migrated the configuration
+// from an older version, we first write a backup of the original file.
 func (cfg *config) WriteFile(env environ, filename string) error {
-	data := append(mustMarshalIndentJSON(cfg, "", "  "), '\n')
-	return env.WriteFile(filename, data, 0644)
+	if cfg.MigratedFrom != configVersion && cfg.OriginalData != nil {
+		backup := fmt.Sprintf("%s.v%d.bak", filename, cfg.MigratedFrom)
+		if err := env.WriteFile(backup, cfg.OriginalData, 0644); err != nil {
+			return err
+		}
+		cfg.MigratedFrom, cfg.OriginalData = configVersion, nil
+	}
+	return env.WriteFile(filename, cfg.Marshal(), 0644)
+}
+
+// Clone returns a deep copy of the configuration.
+func (cfg *config) Clone() *config {
+	return &config{
+		Version:   cfg.Version,
+		Repos:     maps.Clone(cfg.Repos),
+		Settings:  cfg.Settings,
+		Effective: cfg.Effective,
+		Origins:   maps.Clone(cfg.Origins),
+
+		MigratedFrom: cfg.MigratedFrom,
+		OriginalData: cfg.OriginalData,
+	}
+}
+
+// SelectedRepos returns the sorted names of the repositories
+// that are not excluded by the effective settings.
+func (cfg *config) SelectedRepos() []string {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(cfg.Repos)) {
+		if !slices.Contains(cfg.Effective.Exclude, name) {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
-// AddRepo is a convenience method to add a repository to the configuration.
+// AddRepo is a convenience method to add a repository to the configuration,
+// which preserves the tags when the repository already exists.
 func (cfg *config) AddRepo(name, url string) error {
-	cfg.Repos[name] = repoInfo{URL: url}
+	info := cfg.Repos[name]
+	info.URL = url
+	cfg.Repos[name] = info
 	return nil
 }
@@ -0,0 +1,80 @@
+// configmigrate.go - Migrating older configuration files.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// configVersion is the configuration schema version written by this binary.
+const configVersion = 1
+
+// configMigration migrates a raw configuration from the previous schema
+// version to the next one, by modifying the configuration in place.
+type configMigration func(raw map[string]any) error
+
+// configMigrations contains the migrations indexed by the version they
+// migrate from. Configuration files written before we introduced the
+// `version` field implicitly have version zero.
+var configMigrations = []configMigration{
+	// 0 -> 1: introduce the `version` field.
+	func(raw map[string]any) error {
+		if _, found := raw["repos"]; !found {
+			raw["repos"] = map[string]any{}
+		}
+		return nil
+	},
+}
+
+// configVersionOf returns the schema version of the given raw configuration.
+func configVersionOf(raw map[string]any) (int, error) {
+	value, found := raw["version"]
+	if !found {
+		return 0, nil
+	}
+	number, good := value.(float64)
+	if !good || number < 0 || number != float64(int(number)) {
+		return 0, fmt.Errorf("invalid configuration version: %v", value)
+	}
+	return int(number), nil
+}
+
+// migrateConfig migrates the given configuration data to the current schema
+// version. We return the possibly-migrated configuration data and the original
+// schema version. The caller is responsible for persisting the result.
+func migrateConfig(filename string, data []byte) ([]byte, int, error) {
+	// parse the raw configuration
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+	if raw == nil {
+		raw = map[string]any{}
+	}
+
+	// obtain and check the configuration version
+	version, err := configVersionOf(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > configVersion {
+		return nil, 0, fmt.Errorf(
+			"%s uses configuration version %d but this multirepo only supports up to version %d: please upgrade multirepo",
+			filename, version, configVersion,
+		)
+	}
+	if version == configVersion {
+		return data, version, nil
+	}
+
+	// apply the migrations in sequence
+	for current := version; current < configVersion; current++ {
+		if err := configMigrations[current](raw); err != nil {
+			return nil, 0, fmt.Errorf("migrating %s from version %d: %w", filename, current, err)
+		}
+	}
+	raw["version"] = configVersion
+	return append(mustMarshalIndentJSON(raw, "", "  "), '\n'), version, nil
+}
@@ -0,0 +1,85 @@
+// configmigrate_test.go - Tests for migrating older configuration files.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	t.Run("current version is returned unchanged", func(t *testing.T) {
+		data := []byte(`{"version": 1, "repos": {}}`)
+		migrated, version, err := migrateConfig("config.json", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != configVersion || string(migrated) != string(data) {
+			t.Fatalf("unexpected result: %d %s", version, migrated)
+		}
+	})
+
+	t.Run("version zero gains the version and the repos", func(t *testing.T) {
+		migrated, version, err := migrateConfig("config.json", []byte(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 0 {
+			t.Fatalf("expected version 0, got %d", version)
+		}
+		var raw map[string]any
+		if err := json.Unmarshal(migrated, &raw); err != nil {
+			t.Fatal(err)
+		}
+		if raw["version"] != float64(configVersion) {
+			t.Fatalf("unexpected version: %v", raw["version"])
+		}
+		if _, found := raw["repos"]; !found {
+			t.Fatal("expected the repos field")
+		}
+	})
+
+	t.Run("version zero preserves the repos", func(t *testing.T) {
+		data := []byte(`{"repos": {"a": {"url": "git@github.com:x/a", "tags": ["go"]}}}`)
+		migrated, _, err := migrateConfig("config.json", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := parseConfig("config.json", migrated, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info := cfg.Repos["a"]; info.URL != "git@github.com:x/a" || len(info.Tags) != 1 {
+			t.Fatalf("unexpected repos: %+v", cfg.Repos)
+		}
+	})
+
+	t.Run("null is equivalent to an empty object", func(t *testing.T) {
+		if _, version, err := migrateConfig("config.json", []byte(`null`)); err != nil || version != 0 {
+			t.Fatalf("unexpected result: %d %v", version, err)
+		}
+	})
+
+	t.Run("newer versions are rejected", func(t *testing.T) {
+		_, _, err := migrateConfig("config.json", []byte(`{"version": 2}`))
+		if err == nil || !strings.Contains(err.Error(), "please upgrade multirepo") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("invalid versions are rejected", func(t *testing.T) {
+		for _, version := range []string{`-1`, `1.5`, `"1"`} {
+			if _, _, err := migrateConfig("config.json", []byte(`{"version": `+version+`}`)); err == nil {
+				t.Errorf("%s: expected an error", version)
+			}
+		}
+	})
+
+	t.Run("invalid JSON is rejected", func(t *testing.T) {
+		if _, _, err := migrateConfig("config.json", []byte(`{`)); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,201 @@
+// configoverlay.go - Local and user-level configuration overlays.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// configSettings contains the effective settings obtained by merging the
+// settings of the shared configuration with the overlays.
+type configSettings struct {
+	// Exclude lists the repositories to skip when iterating.
+	Exclude []string `json:"exclude"`
+
+	// Jobs is the number of repositories to process in parallel, where
+	// zero means that each command should use its own default.
+	Jobs int `json:"jobs"`
+
+	// LogFile is the default execution log file (see [execLogEnviron]), where
+	// relative paths are relative to the multirepo root and the empty string
+	// means that we should not log the executed subprocesses.
+	LogFile string `json:"log_file"`
+}
+
+// configSettingsLayer contains the settings defined by a configuration file. We use
+// pointers to distinguish between settings that a layer defines and settings that
+// it does not define and should therefore be inherited from the lower layers.
+type configSettingsLayer struct {
+	// Exclude optionally overrides [configSettings.Exclude].
+	Exclude *[]string `json:"exclude,omitempty"`
+
+	// Jobs optionally overrides [configSettings.Jobs].
+	Jobs *int `json:"jobs,omitempty"`
+
+	// LogFile optionally overrides [configSettings.LogFile].
+	LogFile *string `json:"log_file,omitempty"`
+}
+
+// configOverlay is the content of a configuration overlay file.
+type configOverlay struct {
+	// Version is the configuration schema version.
+	Version int `json:"version,omitempty"`
+
+	// Settings contains the settings to override.
+	Settings configSettingsLayer `json:"settings"`
+}
+
+// configOriginDefault is the origin of settings no layer defines.
+const configOriginDefault = "default"
+
+// configSettingsKeys contains the JSON keys of the settings.
+var configSettingsKeys = []string{"exclude", "jobs", "log_file"}
+
+// Validate returns the semantic problems of the settings layer.
+func (layer *configSettingsLayer) Validate() []configIssue {
+	var issues []configIssue
+	if layer.Exclude != nil {
+		for _, name := range *layer.Exclude {
+			if err := validateRepoName(name); err != nil {
+				issues = append(issues, configIssue{[]string{"settings", "exclude"}, err})
+			}
+		}
+	}
+	if layer.Jobs != nil && *layer.Jobs < 0 {
+		err := fmt.Errorf("the number of jobs must not be negative: %d", *layer.Jobs)
+		issues = append(issues, configIssue{[]string{"settings", "jobs"}, err})
+	}
+	if layer.LogFile != nil && strings.ContainsFunc(*layer.LogFile, unicode.IsControl) {
+		err := fmt.Errorf("invalid log file name %q", *layer.LogFile)
+		issues = append(issues, configIssue{[]string{"settings", "log_file"}, err})
+	}
+	return issues
+}
+
+// applySettingsLayer overrides the effective settings with the ones defined
+// by the given layer, recording the given origin for each of them.
+func (cfg *config) applySettingsLayer(layer *configSettingsLayer, origin string) {
+	if layer.Exclude != nil {
+		cfg.Effective.Exclude = slices.Clone(*layer.Exclude)
+		cfg.Origins["settings.exclude"] = origin
+	}
+	if layer.Jobs != nil {
+		cfg.Effective.Jobs = *layer.Jobs
+		cfg.Origins["settings.jobs"] = origin
+	}
+	if layer.LogFile != nil {
+		cfg.Effective.LogFile = *layer.LogFile
+		cfg.Origins["settings.log_file"] = origin
+	}
+}
+
+// mergeConfigOverlays computes the effective settings by merging, in order of
+// increasing precedence, the defaults, the shared configuration read from the given
+// filename, the user-level overlay, and the local overlay. We skip the overlays
+// that do not exist, and we fail if any existing overlay is invalid.
+func mergeConfigOverlays(env environ, filename string, cfg *config) error {
+	// start from the defaults and the shared configuration
+	cfg.Effective = configSettings{Exclude: []string{}, Jobs: 0, LogFile: ""}
+	cfg.Origins = map[string]string{"version": filename, "repos": filename}
+	for _, key := range configSettingsKeys {
+		cfg.Origins["settings."+key] = configOriginDefault
+	}
+	cfg.applySettingsLayer(&cfg.Settings, filename)
+
+	// apply the existing overlays in order of precedence
+	for _, overlayPath := range configOverlayPaths(env, filename) {
+		overlay, err := readConfigOverlay(env, overlayPath)
+		if err != nil {
+			return err
+		}
+		if overlay != nil {
+			cfg.applySettingsLayer(&overlay.Settings, overlayPath)
+		}
+	}
+	return nil
+}
+
+// configOverlayPaths returns the paths of the overlays for the given shared
+// configuration file, in order of increasing precedence.
+func configOverlayPaths(env environ, filename string) []string {
+	var paths []string
+	if userConfig, found := userConfigFilePath(env); found {
+		paths = append(paths, userConfig)
+	}
+	return append(paths, filepath.Join(filepath.Dir(filename), "config.local.json"))
+}
+
+// userConfigFilePath returns the path of the user-level configuration
+// overlay, which lives at `$XDG_CONFIG_HOME/multirepo/config.json`,
+// where `$XDG_CONFIG_HOME` defaults to `$HOME/.config`.
+func userConfigFilePath(env environ) (string, bool) {
+	if dir, found := env.LookupEnv("XDG_CONFIG_HOME"); found && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "multirepo", "config.json"), true
+	}
+	if home, found := env.LookupEnv("HOME"); found && home != "" {
+		return filepath.Join(home, ".config", "multirepo", "config.json"), true
+	}
+	return "", false
+}
+
+// readConfigOverlay reads an overlay file, returning nil if it does not exist.
+func readConfigOverlay(env environ, filename string) (*configOverlay, error) {
+	exists, err := env.FileExists(filename)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := env.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigOverlay(filename, data)
+}
+
+// parseConfigOverlay strictly parses and validates an overlay file.
+func parseConfigOverlay(filename string, data []byte) (*configOverlay, error) {
+	// strictly decode the JSON
+	var overlay configOverlay
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&overlay); err != nil {
+		return nil, locateConfigDecodeError(filename, data, err, isKnownConfigOverlayPath)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, newJSONLocatedError(filename, data, dec.InputOffset(), errors.New("unexpected data after the top-level value"))
+	}
+
+	// make sure we understand the version
+	if overlay.Version < 0 || overlay.Version > configVersion {
+		offset, _ := jsonFindPath(data, "version")
+		err := fmt.Errorf("unsupported configuration version %d (this multirepo supports up to version %d)",
+			overlay.Version, configVersion)
+		return nil, newJSONLocatedError(filename, data, offset, err)
+	}
+
+	// perform the semantic validation
+	if err := locateConfigIssues(filename, data, overlay.Settings.Validate()); err != nil {
+		return nil, err
+	}
+	return &overlay, nil
+}
+
+// isKnownConfigOverlayPath is like [isKnownConfigPath] for overlays.
+func isKnownConfigOverlayPath(path []string) bool {
+	switch {
+	case len(path) == 1:
+		return path[0] == "version" || path[0] == "settings"
+	case len(path) == 2:
+		return path[0] == "settings" && slices.Contains(configSettingsKeys, path[1])
+	default:
+		return false
+	}
+}
@@ -0,0 +1,215 @@
+// configvalidate.go - Strict parsing and validation of the configuration.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// configIssue is a semantic problem at a given configuration path.
+type configIssue struct {
+	// Path is the path of the offending JSON object key.
+	Path []string
+
+	// Err is the problem.
+	Err error
+}
+
+// parseConfig strictly parses the configuration data, rejecting unknown
+// fields, and validates it. The original data is the content of the file
+// before migrating it (see [migrateConfig]), which may differ from the data
+// we parse. Errors refer to lines and columns of the original data, where we
+// locate them using the path of object keys leading to the error.
+func parseConfig(filename string, data, original []byte) (*config, error) {
+	// strictly decode the JSON
+	var cfg config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, locateConfigDecodeError(filename, original, err, isKnownConfigPath)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// the migrated data never contains trailing data, so data equals original
+		return nil, newJSONLocatedError(filename, original, dec.InputOffset(), errors.New("unexpected data after the top-level value"))
+	}
+
+	// ensure the map is not empty
+	if cfg.Repos == nil {
+		cfg.Repos = make(map[string]repoInfo)
+	}
+
+	// perform the semantic validation
+	if err := locateConfigIssues(filename, original, cfg.Validate()); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// locateConfigIssues converts the given issues to errors with line and
+// column information, returning nil when there are no issues.
+func locateConfigIssues(filename string, data []byte, issues []configIssue) error {
+	var errlist []error
+	for _, issue := range issues {
+		offset, _ := jsonFindPath(data, issue.Path...)
+		errlist = append(errlist, newJSONLocatedError(filename, data, offset, issue.Err))
+	}
+	return errors.Join(errlist...)
+}
+
+// checkConfigSyntax adds the line and column to JSON syntax errors. Because
+// the offset of a syntax error counts the offending byte, we subtract one.
+func checkConfigSyntax(filename string, data []byte, err error) error {
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) {
+		return newJSONLocatedError(filename, data, syntaxError.Offset-1, err)
+	}
+	return err
+}
+
+// jsonUnknownFieldRegexp extracts the field name from unknown field errors.
+var jsonUnknownFieldRegexp = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// locateConfigDecodeError adds the line and column to decoding errors. The known
+// function tells whether a path of object keys corresponds to a known field.
+func locateConfigDecodeError(filename string, data []byte, err error, known func(path []string) bool) error {
+	// handle the case of unknown fields, which we locate by name
+	if m := jsonUnknownFieldRegexp.FindStringSubmatch(err.Error()); m != nil {
+		offset, _ := jsonFindKey(data, func(path []string) bool {
+			return path[len(path)-1] == m[1] && !known(path)
+		})
+		return newJSONLocatedError(filename, data, offset, fmt.Errorf("unknown field %q", m[1]))
+	}
+
+	// handle the case of values with the wrong type
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		offset, found := jsonFindPath(data, strings.Split(typeError.Field, ".")...)
+		if !found {
+			offset = typeError.Offset
+		}
+		err := fmt.Errorf("%s: expected %s, found %s", typeError.Field, typeError.Type, typeError.Value)
+		return newJSONLocatedError(filename, data, offset, err)
+	}
+
+	return checkConfigSyntax(filename, data, err)
+}
+
+// isKnownConfigPath returns whether the given path of object keys
+// corresponds to a field we know about.
+func isKnownConfigPath(path []string) bool {
+	switch {
+	case len(path) == 1:
+		return path[0] == "version" || path[0] == "repos" || path[0] == "settings"
+	case len(path) == 2:
+		return path[0] == "repos" || isKnownConfigOverlayPath(path)
+	case len(path) == 3:
+		return path[0] == "repos" && (path[2] == "url" || path[2] == "tags")
+	default:
+		return false
+	}
+}
+
+// Validate returns the semantic problems of the configuration.
+func (cfg *config) Validate() []configIssue {
+	var issues []configIssue
+	seen := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(cfg.Repos)) {
+		// ensure the name is a safe relative path
+		if err := validateRepoName(name); err != nil {
+			issues = append(issues, configIssue{[]string{"repos", name}, err})
+			continue
+		}
+
+		// ensure there are no duplicate directories
+		dir := path.Clean(filepath.ToSlash(name))
+		if other, found := seen[dir]; found {
+			err := fmt.Errorf("repository %q uses the same directory as %q", name, other)
+			issues = append(issues, configIssue{[]string{"repos", name}, err})
+			continue
+		}
+		seen[dir] = name
+
+		// ensure the URL is parseable
+		if err := validateRepoURL(cfg.Repos[name].URL); err != nil {
+			issues = append(issues, configIssue{[]string{"repos", name, "url"}, err})
+		}
+
+		// ensure the tags are usable as comma-separated values
+		for _, tag := range cfg.Repos[name].Tags {
+			if tag == "" || strings.ContainsFunc(tag, isSpaceOrControl) || strings.Contains(tag, ",") {
+				err := fmt.Errorf("invalid tag %q", tag)
+				issues = append(issues, configIssue{[]string{"repos", name, "tags"}, err})
+			}
+		}
+	}
+	return append(issues, cfg.Settings.Validate()...)
+}
+
+// validateRepoName ensures the repository name is a safe relative path.
+func validateRepoName(name string) error {
+	slashed := filepath.ToSlash(name)
+	switch {
+	case name == "":
+		return errors.New("empty repository name")
+	case path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "":
+		return fmt.Errorf("repository name %q is not a relative path", name)
+	case slices.Contains(strings.Split(slashed, "/"), ".."):
+		return fmt.Errorf("repository name %q must not contain '..'", name)
+	case path.Clean(slashed) == ".":
+		return fmt.Errorf("repository name %q does not name a directory", name)
+	case strings.Split(path.Clean(slashed), "/")[0] == dotDirName:
+		return fmt.Errorf("repository name %q is inside the %s directory", name, dotDirName)
+	default:
+		return nil
+	}
+}
+
+// validateRepoURL ensures the repository URL is parseable. The empty URL is valid
+// since `repo add` records an empty URL for repositories without an origin remote.
+func validateRepoURL(URL string) error {
+	// handle the empty and scp-like URL cases
+	if URL == "" {
+		return nil
+	}
+	if strings.ContainsFunc(URL, isSpaceOrControl) {
+		return fmt.Errorf("repository URL %q contains whitespace or control characters", URL)
+	}
+	if _, good := scpLikeParse(URL); good {
+		return nil
+	}
+
+	// handle the URL with scheme case
+	if isSchemeRegExp.MatchString(URL) {
+		parsed, err := url.Parse(URL)
+		if err != nil {
+			return fmt.Errorf("repository URL %q is not parseable: %w", URL, err)
+		}
+		if parsed.Host == "" && parsed.Scheme != "file" {
+			return fmt.Errorf("repository URL %q does not contain a host", URL)
+		}
+		return nil
+	}
+
+	// handle the local path case
+	if filepath.IsAbs(URL) || strings.HasPrefix(URL, "./") || strings.HasPrefix(URL, "../") {
+		return nil
+	}
+	return fmt.Errorf("repository URL %q is neither an URL, an scp-like URL, nor a path", URL)
+}
+
+// isSpaceOrControl returns whether the rune is an ASCII space or control character.
+func isSpaceOrControl(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
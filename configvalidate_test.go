@@ -0,0 +1,116 @@
+// configvalidate_test.go - Tests for the strict configuration validation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// parseConfigFile parses the given file content like [readConfig] does.
+func parseConfigFile(data string) error {
+	migrated, _, err := migrateConfig("config.json", []byte(data))
+	if err != nil {
+		return checkConfigSyntax("config.json", []byte(data), err)
+	}
+	_, err = parseConfig("config.json", migrated, []byte(data))
+	return err
+}
+
+func TestParseConfigLocations(t *testing.T) {
+	cases := []struct {
+		name         string
+		data         string
+		line, column int
+	}{{
+		name: "invalid repository name in a version zero file",
+		data: `{
+  "repos": {
+    "a": {
+      "url": "git@github.com:x/a"
+    },
+    "../b": {
+      "url": "git@github.com:x/b"
+    }
+  }
+}
+`,
+		line:   6,
+		column: 5,
+	}, {
+		name: "invalid repository name in a version one file",
+		data: `{
+  "version": 1,
+  "repos": {
+    "a": {
+      "url": "git@github.com:x/a"
+    },
+    "../b": {
+      "url": "git@github.com:x/b"
+    }
+  }
+}
+`,
+		line:   7,
+		column: 5,
+	}, {
+		name: "unknown field in a version zero file",
+		data: `{
+  "repos": {
+    "a": {
+      "url": "git@github.com:x/a",
+      "branch": "main"
+    }
+  }
+}
+`,
+		line:   5,
+		column: 7,
+	}, {
+		name: "wrong type in a version zero file",
+		data: `{
+  "repos": {
+    "a": {
+      "url": 17
+    }
+  }
+}
+`,
+		line:   4,
+		column: 7,
+	}, {
+		name: "syntax error",
+		data: `{
+  "repos": {,
+}
+`,
+		line:   2,
+		column: 13,
+	}, {
+		name: "trailing data",
+		data: `{"version": 1, "repos": {}}
+{}
+`,
+		line:   2,
+		column: 1,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parseConfigFile(tc.data)
+			var located *jsonLocatedError
+			if !errors.As(err, &located) {
+				t.Fatalf("expected a located error, got %v", err)
+			}
+			if located.Line != tc.line || located.Column != tc.column {
+				t.Fatalf("expected %d:%d, got %s", tc.line, tc.column, err)
+			}
+		})
+	}
+}
+
+func TestParseConfigValid(t *testing.T) {
+	if err := parseConfigFile(`{"repos": {"a": {"url": "git@github.com:x/a"}}}`); err != nil {
+		t.Fatal(err)
+	}
+}
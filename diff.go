@@ -0,0 +1,263 @@
+// diff.go - Unified diffs of text files.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines surrounding each hunk.
+const diffContext = 3
+
+// diffOpKind is the kind of a [diffOp].
+type diffOpKind byte
+
+const (
+	diffEqual  = diffOpKind(' ')
+	diffDelete = diffOpKind('-')
+	diffInsert = diffOpKind('+')
+)
+
+// diffOp is an operation of the edit script transforming the old lines into the new lines.
+type diffOp struct {
+	// Kind is the operation kind.
+	Kind diffOpKind
+
+	// Line is the line, including the trailing newline, if any.
+	Line string
+
+	// OldIdx is the zero-based index of the line in the old lines
+	// (for insertions, the index of the following old line).
+	OldIdx int
+
+	// NewIdx is the zero-based index of the line in the new lines
+	// (for deletions, the index of the following new line).
+	NewIdx int
+}
+
+// unifiedDiff returns the unified diff between the given old and new contents
+// using the given file names, or an empty string if the contents are equal.
+func unifiedDiff(oldName, newName, oldData, newData string) string {
+	if oldData == newData {
+		return ""
+	}
+	ops := diffLines(diffSplitLines(oldData), diffSplitLines(newData))
+	var sb strings.Builder
+	mustFprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].Kind == diffEqual {
+			start++
+		}
+		if start >= len(ops) {
+			break
+		}
+
+		// Extend the hunk until we find more than twice the context of unchanged lines
+		end, equals := start, 0
+		for idx := start; idx < len(ops) && equals <= 2*diffContext; idx++ {
+			if ops[idx].Kind == diffEqual {
+				equals++
+				continue
+			}
+			end, equals = idx+1, 0
+		}
+
+		// Add the context and write the hunk
+		first, last := max(start-diffContext, 0), min(end+diffContext, len(ops))
+		diffWriteHunk(&sb, ops[first:last])
+		start = last
+	}
+	return sb.String()
+}
+
+// diffWriteHunk writes a hunk containing the given operations.
+func diffWriteHunk(sb *strings.Builder, ops []diffOp) {
+	var oldCount, newCount int
+	for _, op := range ops {
+		if op.Kind != diffInsert {
+			oldCount++
+		}
+		if op.Kind != diffDelete {
+			newCount++
+		}
+	}
+	mustFprintf(sb, "@@ -%s +%s @@\n", diffRange(ops[0].OldIdx, oldCount), diffRange(ops[0].NewIdx, newCount))
+	for _, op := range ops {
+		mustFprintf(sb, "%c%s", op.Kind, op.Line)
+		if !strings.HasSuffix(op.Line, "\n") {
+			mustFprintf(sb, "\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// diffRange formats a hunk range given the zero-based index of its first line.
+func diffRange(idx, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", idx)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", idx+1)
+	}
+	return fmt.Sprintf("%d,%d", idx+1, count)
+}
+
+// diffSplitLines splits the given data into lines including the trailing newlines.
+func diffSplitLines(data string) []string {
+	lines := strings.SplitAfter(data, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the edit script transforming a into b using the linear-space
+// variant of the Myers algorithm, after trimming the common prefix and suffix,
+// which usually makes the remaining problem small for the edits we compute.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+	diffMyers(a, b, &ops)
+	return diffNormalize(ops)
+}
+
+// diffMyers appends to ops the edit script transforming a into b, without
+// setting the line indexes (see [diffNormalize]). We split the problem at the
+// middle snake of the shortest edit script and recursively solve the two halves,
+// which requires O(N+M) memory, rather than remembering the furthest reaching
+// paths of each step, which requires O(D*(N+M)) memory and is thus too much for
+// large rewrites (e.g., of `go.sum`).
+func diffMyers(a, b []string, ops *[]diffOp) {
+	// Trim the common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		*ops = append(*ops, diffOp{Kind: diffEqual, Line: line})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// Solve the trivial cases or split at the middle snake, which, since
+	// the remaining parts have neither a common prefix nor a common suffix
+	// and are not empty, requires at least two edits, such that each half
+	// is a smaller problem than the whole one
+	switch {
+	case len(middleA) <= 0:
+		for _, line := range middleB {
+			*ops = append(*ops, diffOp{Kind: diffInsert, Line: line})
+		}
+	case len(middleB) <= 0:
+		for _, line := range middleA {
+			*ops = append(*ops, diffOp{Kind: diffDelete, Line: line})
+		}
+	default:
+		x, y, u, v := diffMiddleSnake(middleA, middleB)
+		diffMyers(middleA[:x], middleB[:y], ops)
+		for _, line := range middleA[x:u] {
+			*ops = append(*ops, diffOp{Kind: diffEqual, Line: line})
+		}
+		diffMyers(middleA[u:], middleB[v:], ops)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		*ops = append(*ops, diffOp{Kind: diffEqual, Line: line})
+	}
+}
+
+// diffMiddleSnake returns the start (x, y) and the end (u, v) of the middle snake
+// of a shortest edit script transforming a into b, which MUST NOT be empty, by
+// searching forward from the start and backward from the end at the same time
+// until the furthest reaching paths overlap (see "An O(ND) Difference Algorithm
+// and Its Variations" by Eugene W. Myers, Section 4b).
+func diffMiddleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// forward[offset+k] is the furthest x reached on the diagonal k = x - y
+	// starting from (0, 0), while backward[offset+k] is the furthest distance
+	// from the end reached on the diagonal k of the reversed sequences
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for d := 0; d <= maxD; d++ {
+		// Extend the forward paths and check for overlaps with the backward
+		// paths of the previous step, which may only occur when delta is odd
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			if rk := delta - k; delta%2 != 0 && rk >= -(d-1) && rk <= d-1 && x >= n-backward[offset+rk] {
+				return x0, y0, x, y
+			}
+		}
+
+		// Extend the backward paths and check for overlaps with the forward
+		// paths of this step, which may only occur when delta is even
+		for rk := -d; rk <= d; rk += 2 {
+			var rx int
+			if rk == -d || (rk != d && backward[offset+rk-1] < backward[offset+rk+1]) {
+				rx = backward[offset+rk+1]
+			} else {
+				rx = backward[offset+rk-1] + 1
+			}
+			ry := rx - rk
+			rx0, ry0 := rx, ry
+			for rx < n && ry < m && a[n-1-rx] == b[m-1-ry] {
+				rx, ry = rx+1, ry+1
+			}
+			backward[offset+rk] = rx
+			if k := delta - rk; delta%2 == 0 && k >= -d && k <= d && forward[offset+k] >= n-rx {
+				return n - rx, m - ry, n - rx0, m - ry0
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+// diffNormalize moves the deletions before the insertions within each change,
+// like `diff -u` does, and sets the line indexes of the operations.
+func diffNormalize(ops []diffOp) []diffOp {
+	out := make([]diffOp, 0, len(ops))
+	oldIdx, newIdx := 0, 0
+	for idx := 0; idx < len(ops); {
+		if ops[idx].Kind == diffEqual {
+			out = append(out, diffOp{Kind: diffEqual, Line: ops[idx].Line, OldIdx: oldIdx, NewIdx: newIdx})
+			oldIdx, newIdx, idx = oldIdx+1, newIdx+1, idx+1
+			continue
+		}
+		end := idx
+		for end < len(ops) && ops[end].Kind != diffEqual {
+			end++
+		}
+		for _, op := range ops[idx:end] {
+			if op.Kind == diffDelete {
+				out = append(out, diffOp{Kind: diffDelete, Line: op.Line, OldIdx: oldIdx, NewIdx: newIdx})
+				oldIdx++
+			}
+		}
+		for _, op := range ops[idx:end] {
+			if op.Kind == diffInsert {
+				out = append(out, diffOp{Kind: diffInsert, Line: op.Line, OldIdx: oldIdx, NewIdx: newIdx})
+				newIdx++
+			}
+		}
+		idx = end
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+// diff_test.go - Tests for the unified diffs of text files.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name    string
+		oldData string
+		newData string
+		expect  string
+	}{{
+		name:    "equal contents",
+		oldData: "a\nb\n",
+		newData: "a\nb\n",
+		expect:  "",
+	}, {
+		name:    "insertion",
+		oldData: "a\nb\nc\n",
+		newData: "a\nb\nx\nc\n",
+		expect:  "--- a/f\n+++ b/f\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+	}, {
+		name:    "deletion",
+		oldData: "a\nb\nc\n",
+		newData: "a\nc\n",
+		expect:  "--- a/f\n+++ b/f\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+	}, {
+		name:    "replacement",
+		oldData: "a\nb\nc\n",
+		newData: "a\nx\ny\nc\n",
+		expect:  "--- a/f\n+++ b/f\n@@ -1,3 +1,4 @@\n a\n-b\n+x\n+y\n c\n",
+	}, {
+		name:    "from empty",
+		oldData: "",
+		newData: "a\nb\n",
+		expect:  "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+	}, {
+		name:    "to empty",
+		oldData: "a\n",
+		newData: "",
+		expect:  "--- a/f\n+++ b/f\n@@ -1 +0,0 @@\n-a\n",
+	}, {
+		name:    "no newline at end of file",
+		oldData: "a\nb",
+		newData: "a\nb\n",
+		expect:  "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+	}, {
+		name:    "separate hunks",
+		oldData: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+		newData: "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n",
+		expect: "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n" +
+			"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+y\n",
+	}, {
+		name:    "merged hunks",
+		oldData: "1\n2\n3\n4\n5\n6\n7\n8\n",
+		newData: "x\n2\n3\n4\n5\n6\n7\ny\n",
+		expect:  "--- a/f\n+++ b/f\n@@ -1,8 +1,8 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n",
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unifiedDiff("a/f", "b/f", tc.oldData, tc.newData); got != tc.expect {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for idx := range lines {
+			lines[idx] = fmt.Sprintf("%c\n", 'a'+rng.Intn(4))
+		}
+		return lines
+	}
+	for iter := 0; iter < 2000; iter++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+		diffCheckScript(t, a, b, ops)
+		if got, expect := diffCountEdits(ops), len(a)+len(b)-2*diffLCSLength(a, b); got != expect {
+			t.Fatalf("%q -> %q: expected %d edits, got %d", a, b, expect, got)
+		}
+	}
+}
+
+func TestDiffLinesLargeRewrite(t *testing.T) {
+	const count = 4000
+	a, b := make([]string, count), make([]string, count)
+	for idx := range count {
+		a[idx] = fmt.Sprintf("old %d\n", idx)
+		b[idx] = fmt.Sprintf("new %d\n", idx)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+	diffCheckScript(t, a, b, ops)
+	if got := diffCountEdits(ops); got != 2*count {
+		t.Fatalf("expected %d edits, got %d", 2*count, got)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Fatalf("allocated too much memory: %d bytes", allocated)
+	}
+}
+
+// diffCheckScript fails the test unless ops is a valid edit script transforming
+// a into b with consistent indexes and deletions preceding insertions.
+func diffCheckScript(t *testing.T, a, b []string, ops []diffOp) {
+	t.Helper()
+	var oldIdx, newIdx int
+	for idx, op := range ops {
+		if op.OldIdx != oldIdx || op.NewIdx != newIdx {
+			t.Fatalf("op %d: expected indexes %d,%d, got %d,%d", idx, oldIdx, newIdx, op.OldIdx, op.NewIdx)
+		}
+		switch op.Kind {
+		case diffEqual:
+			if oldIdx >= len(a) || newIdx >= len(b) || a[oldIdx] != op.Line || b[newIdx] != op.Line {
+				t.Fatalf("op %d: invalid equal line %q", idx, op.Line)
+			}
+			oldIdx, newIdx = oldIdx+1, newIdx+1
+		case diffDelete:
+			if oldIdx >= len(a) || a[oldIdx] != op.Line {
+				t.Fatalf("op %d: invalid deleted line %q", idx, op.Line)
+			}
+			if idx > 0 && ops[idx-1].Kind == diffInsert {
+				t.Fatalf("op %d: deletion following an insertion", idx)
+			}
+			oldIdx++
+		case diffInsert:
+			if newIdx >= len(b) || b[newIdx] != op.Line {
+				t.Fatalf("op %d: invalid inserted line %q", idx, op.Line)
+			}
+			newIdx++
+		}
+	}
+	if oldIdx != len(a) || newIdx != len(b) {
+		t.Fatalf("incomplete edit script: %d/%d old lines, %d/%d new lines", oldIdx, len(a), newIdx, len(b))
+	}
+}
+
+// diffCountEdits returns the number of insertions and deletions.
+func diffCountEdits(ops []diffOp) int {
+	var count int
+	for _, op := range ops {
+		if op.Kind != diffEqual {
+			count++
+		}
+	}
+	return count
+}
+
+// diffLCSLength returns the length of the longest common subsequence.
+func diffLCSLength(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for idx := range table {
+		table[idx] = make([]int, len(b)+1)
+	}
+	for x := len(a) - 1; x >= 0; x-- {
+		for y := len(b) - 1; y >= 0; y-- {
+			if a[x] == b[y] {
+				table[x][y] = table[x+1][y+1] + 1
+			} else {
+				table[x][y] = max(table[x+1][y], table[x][y+1])
+			}
+		}
+	}
+	return table[0][0]
+}
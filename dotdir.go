@@ -3,14 +3,50 @@
 
 package main
 
-import "path/filepath"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+)
 
 // dotDir is the multirepo dot directory.
 type dotDir string
 
-// defaultDotDir is the default multirepo dot directory.
-func defaultDotDir() dotDir {
-	return ".multirepo"
+// dotDirName is the name of the multirepo dot directory.
+const dotDirName = ".multirepo"
+
+// foreachRootEnv is the environment variable containing the absolute path of the
+// multirepo root, which `multirepo foreach` sets for the commands it executes.
+const foreachRootEnv = "MULTIREPO_FOREACH_ROOT"
+
+// defaultDotDir returns the default multirepo dot directory, which is inside the
+// current directory unless we are running inside a repository of a `multirepo
+// foreach` invocation. In such a case, the dot directory lives inside the root
+// named by [foreachRootEnv], which allows nested invocations (e.g., `multirepo
+// foreach multirepo repo ls`) to find the dot directory. We ignore the variable
+// when the current directory is outside of the root, such that, e.g., `cd /tmp
+// && multirepo init` executed by `multirepo foreach` does what it says.
+func defaultDotDir(env environ) dotDir {
+	root, found := env.LookupEnv(foreachRootEnv)
+	if !found || root == "" {
+		return dotDirName
+	}
+	cwd, err := env.Getwd()
+	if err != nil {
+		return dotDirName
+	}
+	rel, err := filepath.Rel(root, cwd)
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return dotDirName
+	}
+	return dotDir(filepath.Join(root, dotDirName))
 }
 
 // String returns the string representation of the dot directory.
@@ -18,13 +54,211 @@ func (dd dotDir) String() string {
 	return string(dd)
 }
 
+// rootDir returns the multirepo root directory containing the dot directory.
+func (dd dotDir) rootDir() string {
+	return filepath.Dir(dd.String())
+}
+
+// repoPath returns the path of the given repository directory.
+func (dd dotDir) repoPath(name string) string {
+	return filepath.Join(dd.rootDir(), name)
+}
+
+// repoNameFromPath returns the name of the repository at the given path, which
+// is relative to the current directory, i.e., its slash-separated path relative
+// to the multirepo root, failing if the path is not inside the root.
+func (dd dotDir) repoNameFromPath(env environ, path string) (string, error) {
+	root, err := env.AbsFilepath(dd.rootDir())
+	if err != nil {
+		return "", err
+	}
+	abspath, err := env.AbsFilepath(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abspath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s: not inside the multirepo root %s", path, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
 // configFilePath returns the path to the configuration file.
 func (dd dotDir) configFilePath() string {
 	return filepath.Join(dd.String(), "config.json")
 }
 
-// lock locks the dot directory until it is released.
-func (dd dotDir) lock(env environ) (lockReleaser, error) {
+// journalFilePath returns the path to the journal of index mutations.
+func (dd dotDir) journalFilePath() string {
+	return filepath.Join(dd.String(), "journal.jsonl")
+}
+
+// foreachFailedFilePath returns the path to the file containing the
+// repositories where the most recent `foreach` invocation failed.
+func (dd dotDir) foreachFailedFilePath() string {
+	return filepath.Join(dd.String(), "foreach.failed.json")
+}
+
+// topicsFilePath returns the path to the file containing the topics.
+func (dd dotDir) topicsFilePath() string {
+	return filepath.Join(dd.String(), "topics.json")
+}
+
+// lockHoldersDirPath returns the path to the directory where the processes
+// holding the lock describe themselves to the processes waiting for it.
+func (dd dotDir) lockHoldersDirPath() string {
+	return filepath.Join(dd.String(), "lock.holders")
+}
+
+// lockMode is the mode with which we lock the dot directory.
+type lockMode int
+
+const (
+	// lockShared allows other processes to concurrently hold a shared lock,
+	// and is suitable for commands that do not modify the dot directory.
+	lockShared = lockMode(iota)
+
+	// lockExclusive prevents other processes from holding any lock, and is
+	// suitable for commands that modify the dot directory.
+	lockExclusive
+)
+
+// String returns the string representation of the lock mode.
+func (mode lockMode) String() string {
+	if mode == lockShared {
+		return "shared"
+	}
+	return "exclusive"
+}
+
+// lockPollInterval is the interval between attempts to acquire the
+// lock when waiting for the lock with a timeout.
+const lockPollInterval = 50 * time.Millisecond
+
+// lock locks the dot directory using the given mode until it is released.
+//
+// A negative timeout means waiting indefinitely for processes holding a
+// conflicting lock, zero means not waiting at all, and a positive value
+// bounds the waiting time. When we fail to acquire the lock because of the
+// timeout, the error describes the processes holding the lock.
+func (dd dotDir) lock(env environ, mode lockMode, timeout time.Duration) (lockReleaser, error) {
+	// Acquire the lock, possibly polling until the deadline
 	lpath := filepath.Join(dd.String(), "lock")
-	return env.CreateLockFile(lpath)
+	shared := mode == lockShared
+	deadline := time.Now().Add(timeout)
+	var (
+		release lockReleaser
+		err     error
+	)
+	for {
+		release, err = env.CreateLockFile(lpath, shared, timeout < 0)
+		if !errors.Is(err, errLockBusy) || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(lockPollInterval)
+	}
+	if errors.Is(err, errLockBusy) {
+		return nil, dd.lockBusyError(env)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the descriptions left behind by processes that did not release
+	// the lock cleanly, which are all the existing ones when we hold the lock
+	// exclusively, and describe ourselves to processes waiting for the lock
+	if mode == lockExclusive {
+		dd.removeLockHolders(env)
+	}
+	return dd.registerLockHolder(env, mode, release), nil
+}
+
+// registerLockHolder describes the current process to other processes waiting
+// for the lock and returns a [lockReleaser] that removes the description before
+// releasing the lock. This is best effort because the description only serves to
+// produce better error messages. Since the description is process metadata tied
+// to the lock itself rather than a multirepo mutation, we also write it in
+// dry-run mode (see [unwrapPrintingEnviron]).
+func (dd dotDir) registerLockHolder(env environ, mode lockMode, release lockReleaser) lockReleaser {
+	env = unwrapPrintingEnviron(env)
+	holder := &lockHolder{
+		PID:   os.Getpid(),
+		Argv:  journalArgv(env),
+		Mode:  mode.String(),
+		Since: time.Now(),
+	}
+
+	// Note: we do not want to create the dot directory here, which
+	// does not exist when we lock it in dry-run mode (e.g., with `init`)
+	if exists, err := env.DirExists(dd.String()); err != nil || !exists {
+		return release
+	}
+	if err := env.MkdirAll(dd.lockHoldersDirPath(), 0700); err != nil {
+		return release
+	}
+	hpath := dd.lockHolderFilePath(holder.PID)
+	if env.WriteFile(hpath, mustMarshalJSON(holder), 0600) != nil {
+		return release
+	}
+	return func() {
+		env.Remove(hpath)
+		release()
+	}
+}
+
+// lockHolderFilePath returns the path to the file describing the process
+// with the given PID, which holds the lock.
+func (dd dotDir) lockHolderFilePath(pid int) string {
+	return filepath.Join(dd.lockHoldersDirPath(), strconv.Itoa(pid)+".json")
+}
+
+// removeLockHolders removes the descriptions of the processes holding the
+// lock. You MUST only invoke this function when holding the exclusive lock,
+// such that all the existing descriptions are stale.
+func (dd dotDir) removeLockHolders(env environ) {
+	env = unwrapPrintingEnviron(env)
+	entries, _ := env.ReadDir(dd.lockHoldersDirPath())
+	for _, entry := range entries {
+		env.Remove(filepath.Join(dd.lockHoldersDirPath(), entry.Name()))
+	}
+}
+
+// lockHolder describes a process holding the lock.
+type lockHolder struct {
+	// PID is the process ID.
+	PID int `json:"pid"`
+
+	// Argv is the command line of the process.
+	Argv []string `json:"argv"`
+
+	// Mode is the lock mode.
+	Mode string `json:"mode"`
+
+	// Since is when the process acquired the lock.
+	Since time.Time `json:"since"`
+}
+
+// lockBusyError returns an error describing the processes holding the lock,
+// ignoring the stale descriptions of processes that no longer exist.
+func (dd dotDir) lockBusyError(env environ) error {
+	entries, _ := env.ReadDir(dd.lockHoldersDirPath())
+	var descriptions []string
+	for _, entry := range entries {
+		data, err := env.ReadFile(filepath.Join(dd.lockHoldersDirPath(), entry.Name()))
+		if err != nil {
+			continue
+		}
+		var holder lockHolder
+		if err := json.Unmarshal(data, &holder); err != nil || !procAlive(holder.PID) {
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf(
+			"locked (%s) by PID %d running `%s` since %s", holder.Mode, holder.PID,
+			shellquote.Join(holder.Argv...), holder.Since.Local().Format(time.DateTime),
+		))
+	}
+	if len(descriptions) <= 0 {
+		return fmt.Errorf("%s: %w", dd, errLockBusy)
+	}
+	return fmt.Errorf("%s: %w: %s", dd, errLockBusy, strings.Join(descriptions, "; "))
 }
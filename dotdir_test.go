@@ -0,0 +1,67 @@
+// dotdir_test.go - Tests for the `.multirepo` dot directory.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDotDirLockHolders(t *testing.T) {
+	env := newStdlibEnviron()
+	dd := dotDir(filepath.Join(t.TempDir(), dotDirName))
+	if err := os.MkdirAll(dd.lockHoldersDirPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend that a process that no longer exists did not release the lock cleanly
+	const stalePID = 1<<22 + 1 // larger than the largest Linux PID
+	stale := &lockHolder{PID: stalePID, Argv: []string{"multirepo", "stale"}, Mode: "shared", Since: time.Now()}
+	if err := os.WriteFile(dd.lockHolderFilePath(stalePID), mustMarshalJSON(stale), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("busy errors ignore stale holders", func(t *testing.T) {
+		err := dd.lockBusyError(env)
+		if !errors.Is(err, errLockBusy) || strings.Contains(err.Error(), "stale") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("exclusive locks remove stale holders", func(t *testing.T) {
+		unlock, err := dd.lock(env, lockExclusive, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(dd.lockHolderFilePath(stalePID)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected the stale holder to be removed, got %v", err)
+		}
+		if _, err := os.Stat(dd.lockHolderFilePath(os.Getpid())); err != nil {
+			t.Fatalf("expected our holder to exist, got %v", err)
+		}
+		if err := dd.lockBusyError(env); !strings.Contains(err.Error(), "locked (exclusive)") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		unlock()
+		if _, err := os.Stat(dd.lockHolderFilePath(os.Getpid())); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected our holder to be removed, got %v", err)
+		}
+	})
+
+	t.Run("dry runs do not create the dot directory", func(t *testing.T) {
+		missing := dotDir(filepath.Join(t.TempDir(), dotDirName))
+		unlock, err := missing.lock(newDryRunEnviron(env), lockExclusive, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unlock()
+		if _, err := os.Stat(missing.String()); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected the dot directory not to exist, got %v", err)
+		}
+	})
+}
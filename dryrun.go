@@ -0,0 +1,112 @@
+// dryrun.go - Dry-run implementation of the environment.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/kballard/go-shellquote"
+)
+
+// dryRunEnviron is an [environ] that prints the commands and the file-system
+// mutations it would perform instead of performing them, while still running
+// read-only operations such as [environ.RunQueryCommand].
+//
+// The zero value is not ready to use. Construct using [newDryRunEnviron].
+type dryRunEnviron struct {
+	environ
+
+	// xl is the logger used to print what we would do.
+	xl *xLogger
+}
+
+var _ environ = (*dryRunEnviron)(nil)
+
+// newDryRunEnviron creates a new [*dryRunEnviron] wrapping the given [environ].
+func newDryRunEnviron(env environ) *dryRunEnviron {
+	return &dryRunEnviron{
+		environ: env,
+		xl:      newXLogger(newNilSafeLipglossStyle(), env.Stderr()),
+	}
+}
+
+// unwrapPrintingEnviron returns the environment wrapped by the [*dryRunEnviron]
+// and the [*xLogEnviron] wrapping the given environment, if any. We use it to
+// manage the lock metadata, which, like the lock itself, is not a mutation,
+// hence we neither print it nor skip it in dry-run mode.
+func unwrapPrintingEnviron(env environ) environ {
+	for {
+		switch wrapper := env.(type) {
+		case *dryRunEnviron:
+			env = wrapper.environ
+		case *xLogEnviron:
+			env = wrapper.environ
+		default:
+			return env
+		}
+	}
+}
+
+// dryRunSuffix is the shell comment we append to the printed commands.
+const dryRunSuffix = "  # dry run"
+
+// AppendFile implements the [environ] interface.
+func (env *dryRunEnviron) AppendFile(filename string, data []byte, perm os.FileMode) error {
+	env.xl.Logf("%s%s", xLogWriteFileString(filename, data, ">>"), dryRunSuffix)
+	return nil
+}
+
+// CreateLockFile implements the [environ] interface.
+//
+// We acquire the lock to read a consistent state. However, in dry-run mode the
+// dot directory may not exist (e.g., with `init`), in which case there is nothing
+// to protect and we return a no-op [lockReleaser].
+func (env *dryRunEnviron) CreateLockFile(path string, shared, wait bool) (lockReleaser, error) {
+	release, err := env.environ.CreateLockFile(path, shared, wait)
+	if errors.Is(err, fs.ErrNotExist) {
+		return func() {}, nil
+	}
+	return release, err
+}
+
+// MkdirAll implements the [environ] interface.
+func (env *dryRunEnviron) MkdirAll(path string, perm os.FileMode) error {
+	env.xl.Logf("mkdir -p %s%s", shellquote.Join(path), dryRunSuffix)
+	return nil
+}
+
+// Remove implements the [environ] interface.
+func (env *dryRunEnviron) Remove(path string) error {
+	env.xl.Logf("rm -f %s%s", shellquote.Join(path), dryRunSuffix)
+	return nil
+}
+
+// RunCommand implements the [environ] interface.
+func (env *dryRunEnviron) RunCommand(cmd *exec.Cmd) error {
+	env.xl.Logf("%s%s", xLogCmdString(cmd), dryRunSuffix)
+	return nil
+}
+
+// WriteFile implements the [environ] interface.
+func (env *dryRunEnviron) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	env.xl.Logf("%s%s", xLogWriteFileString(filename, data, ">"), dryRunSuffix)
+	return nil
+}
+
+// addDryRunFlag adds the `--dry-run` flag to the given [*nflag.FlagSet].
+func addDryRunFlag(fset *nflag.FlagSet) *bool {
+	return fset.Bool("dry-run", 0, "Print the commands and file changes without executing them.")
+}
+
+// honourDryRunFlag replaces the environment with a [*dryRunEnviron] if needed.
+func honourDryRunFlag(args *clip.CommandArgs[environ], dryRun bool) {
+	if dryRun {
+		args.Env = newDryRunEnviron(args.Env)
+	}
+}
@@ -5,12 +5,12 @@ package main
 
 import (
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 
 	"github.com/bassosimone/clip"
-	"github.com/rogpeppe/go-internal/lockedfile"
 )
 
 // lockReleaser is a function that releases a lock on the dot directory.
@@ -24,8 +24,18 @@ type environ interface {
 	// AbsFilepath returns the absolute path of the given path.
 	AbsFilepath(path string) (string, error)
 
-	// CreateLockFile creates a lockfile at the given path.
-	CreateLockFile(path string) (lockReleaser, error)
+	// AppendFile appends the given data to the given file using a single write,
+	// creating the file with the given permissions if it does not exist.
+	AppendFile(filename string, data []byte, perm os.FileMode) error
+
+	// CreateLockFile creates a lockfile at the given path and locks it. When
+	// shared is true, the lock is shared, otherwise it is exclusive. When wait
+	// is false, this method fails with [errLockBusy] instead of waiting for a
+	// conflicting lock held by another process to be released.
+	CreateLockFile(path string, shared, wait bool) (lockReleaser, error)
+
+	// DirExists checks if a file exists and is a directory.
+	DirExists(path string) (bool, error)
 
 	// Environ returns the OS environment.
 	Environ() []string
@@ -48,15 +58,28 @@ type environ interface {
 	// MkdirAll creates a directory and all its parents if they do not exist.
 	MkdirAll(path string, perm os.FileMode) error
 
+	// ReadDir reads the given directory and returns its entries sorted by name.
+	ReadDir(path string) ([]fs.DirEntry, error)
+
 	// ReadFile reads the given file and returns its contents.
 	ReadFile(filename string) ([]byte, error)
 
+	// Remove removes the given file or empty directory.
+	Remove(path string) error
+
 	// RunCommand runs the given [*exec.Cmd].
 	RunCommand(cmd *exec.Cmd) error
 
+	// RunQueryCommand runs the given [*exec.Cmd], which MUST NOT modify the
+	// state (e.g., `git config --get`), such that we can run it in dry-run mode.
+	RunQueryCommand(cmd *exec.Cmd) error
+
 	// SignalNotify registers a channel to receive notifications of signals.
 	SignalNotify(ch chan<- os.Signal, sig ...os.Signal)
 
+	// Stat returns information about the given file following symbolic links.
+	Stat(path string) (fs.FileInfo, error)
+
 	// Stdin returns the standard input.
 	Stdin() io.Reader
 
@@ -94,9 +117,27 @@ func (env *stdlibEnviron) AbsFilepath(path string) (string, error) {
 	return filepath.Abs(path)
 }
 
+// AppendFile implements the [environ] interface.
+func (*stdlibEnviron) AppendFile(filename string, data []byte, perm os.FileMode) error {
+	filep, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := filep.Write(data); err != nil {
+		filep.Close()
+		return err
+	}
+	return filep.Close()
+}
+
 // CreateLockFile implements the [environ] interface.
-func (env *stdlibEnviron) CreateLockFile(path string) (lockReleaser, error) {
-	return lockedfile.MutexAt(path).Lock()
+func (env *stdlibEnviron) CreateLockFile(path string, shared, wait bool) (lockReleaser, error) {
+	return fsxLockFile(path, shared, wait)
+}
+
+// DirExists implements the [environ] interface.
+func (env *stdlibEnviron) DirExists(path string) (bool, error) {
+	return fsxDirExists(path)
 }
 
 // Environ implements the [environ] interface.
@@ -124,16 +165,36 @@ func (*stdlibEnviron) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
 
+// ReadDir implements the [environ] interface.
+func (*stdlibEnviron) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
 // ReadFile implements the [environ] interface.
 func (*stdlibEnviron) ReadFile(filename string) ([]byte, error) {
 	return os.ReadFile(filename)
 }
 
+// Remove implements the [environ] interface.
+func (*stdlibEnviron) Remove(path string) error {
+	return os.Remove(path)
+}
+
 // RunCommand implements the [environ] interface.
 func (*stdlibEnviron) RunCommand(cmd *exec.Cmd) error {
 	return cmd.Run()
 }
 
+// RunQueryCommand implements the [environ] interface.
+func (*stdlibEnviron) RunQueryCommand(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// Stat implements the [environ] interface.
+func (*stdlibEnviron) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
 // WriteFile implements the [environ] interface.
 func (*stdlibEnviron) WriteFile(filename string, data []byte, perm os.FileMode) error {
 	return os.WriteFile(filename, data, perm)
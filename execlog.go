@@ -0,0 +1,280 @@
+// execlog.go - Structured log of the executed subprocesses.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// execLogMaxStderr is the maximum number of stderr bytes we log for each
+// subprocess. We keep the tail, which usually explains why it failed.
+const execLogMaxStderr = 4096
+
+// execLogEntry is an entry of the execution log.
+type execLogEntry struct {
+	// Repo is the name of the repository in which we run the subprocess,
+	// or empty if the subprocess did not run inside a repository.
+	Repo string `json:"repo"`
+
+	// Argv contains the subprocess command line.
+	Argv []string `json:"argv"`
+
+	// Cwd is the absolute working directory of the subprocess.
+	Cwd string `json:"cwd"`
+
+	// Start is when we started the subprocess.
+	Start time.Time `json:"start"`
+
+	// DurationMs is the subprocess duration in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+
+	// ExitCode is the subprocess exit code, which is -1 when we
+	// could not start it or a signal terminated it.
+	ExitCode int `json:"exit_code"`
+
+	// Error is the error that occurred, if any.
+	Error string `json:"error,omitempty"`
+
+	// Stderr contains the tail of the subprocess standard error.
+	Stderr string `json:"stderr"`
+
+	// StderrTruncated indicates that we truncated Stderr.
+	StderrTruncated bool `json:"stderr_truncated,omitempty"`
+}
+
+// execLogEnviron is an [environ] that appends an [execLogEntry] to the log
+// file for each subprocess run using [environ.RunCommand] or [environ.RunQueryCommand].
+//
+// The zero value is not ready to use. Construct using [newExecLogEnviron].
+type execLogEnviron struct {
+	environ
+
+	// filename is the absolute path of the log file or empty
+	// if we do not know the log file yet.
+	filename string
+
+	// mu protects filename and serializes writing to the log file.
+	mu sync.Mutex
+
+	// root is the absolute path of the multirepo root.
+	root string
+}
+
+var _ environ = (*execLogEnviron)(nil)
+
+// newExecLogEnviron creates a new [*execLogEnviron] wrapping the given [environ]. The
+// root is the multirepo root, which we use to map working directories to repositories.
+func newExecLogEnviron(env environ, filename, root string) *execLogEnviron {
+	return &execLogEnviron{environ: env, filename: filename, root: root}
+}
+
+// RunCommand implements the [environ] interface.
+func (env *execLogEnviron) RunCommand(cmd *exec.Cmd) error {
+	return env.run(cmd, env.environ.RunCommand)
+}
+
+// RunQueryCommand implements the [environ] interface.
+func (env *execLogEnviron) RunQueryCommand(cmd *exec.Cmd) error {
+	return env.run(cmd, env.environ.RunQueryCommand)
+}
+
+// run runs the given command using the given function and logs the result.
+func (env *execLogEnviron) run(cmd *exec.Cmd, runfn func(cmd *exec.Cmd) error) error {
+	if !env.logging() {
+		return runfn(cmd)
+	}
+
+	// Capture the tail of the standard error
+	tail := &execLogTailWriter{}
+	execLogCaptureStderr(cmd, tail)
+
+	// Run the command
+	start := time.Now()
+	err := runfn(cmd)
+	entry := execLogEntry{
+		Repo:            "",
+		Argv:            cmd.Args,
+		Cwd:             cmd.Dir,
+		Start:           start.UTC(),
+		DurationMs:      time.Since(start).Milliseconds(),
+		ExitCode:        0,
+		Stderr:          string(tail.data),
+		StderrTruncated: tail.truncated,
+	}
+
+	// Determine the exit code
+	if err != nil {
+		entry.Error = err.Error()
+		entry.ExitCode = commandExitCode(err)
+	}
+
+	// Determine the working directory and the repository
+	if cwd, err := env.AbsFilepath(cmd.Dir); err == nil {
+		entry.Cwd = cwd
+		entry.Repo = env.repoName(cwd)
+	}
+
+	// Append the entry, warning without failing the command on errors
+	if err := env.append(&entry); err != nil {
+		mustFprintf(env.Stderr(), "multirepo: cannot write the execution log: %s\n", err)
+	}
+	return err
+}
+
+// execLogCaptureStderr configures the given command to also write its standard
+// error to the given writer, preserving the behavior of [exec.Cmd]:
+//
+// 1. when stdout and stderr are the same writer, which [exec.Cmd] writes using
+// a single goroutine, we replace both with the same writer, thus capturing the
+// tail of the combined output but keeping the writes serialized;
+//
+// 2. when stderr is a terminal, which the subprocess inherits, we do not
+// capture, such that the subprocess still writes directly to the terminal
+// (e.g., in `foreach -i`) rather than to a pipe;
+//
+// 3. otherwise, we tee stderr.
+func execLogCaptureStderr(cmd *exec.Cmd, tail io.Writer) {
+	switch {
+	case cmd.Stderr == nil:
+		cmd.Stderr = tail
+	case isTerminal(cmd.Stderr):
+		// let the subprocess inherit the terminal
+	case execLogSameWriter(cmd.Stdout, cmd.Stderr):
+		w := io.MultiWriter(cmd.Stderr, tail)
+		cmd.Stdout, cmd.Stderr = w, w
+	default:
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, tail)
+	}
+}
+
+// execLogSameWriter is like `a == b` but returns false rather than
+// panicking when the writers have the same non-comparable type.
+func execLogSameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// commandExitCode returns the exit code corresponding to the error returned
+// by running a subprocess, where -1 means that we could not start it or a
+// signal terminated it.
+func commandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// repoName returns the name of the repository corresponding to the given absolute
+// working directory, i.e., its path relative to the multirepo root, or an empty
+// string if it is the root itself, the dot directory, or outside of the root.
+func (env *execLogEnviron) repoName(cwd string) string {
+	rel, err := filepath.Rel(env.root, cwd)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return ""
+	}
+	name := filepath.ToSlash(rel)
+	if name == dotDirName || strings.HasPrefix(name, dotDirName+"/") {
+		return ""
+	}
+	return name
+}
+
+// logging returns whether we know the log file, which we may only learn
+// when the command reads its configuration (see [honourLogFileSetting]).
+func (env *execLogEnviron) logging() bool {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.filename != ""
+}
+
+// append appends the given entry to the log file. We write each entry using
+// a single append-mode write so that concurrent processes (e.g., nested
+// `multirepo foreach` invocations) do not interleave their entries.
+func (env *execLogEnviron) append(entry *execLogEntry) error {
+	data := append(mustMarshalJSON(entry), '\n')
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.environ.AppendFile(env.filename, data, 0644)
+}
+
+// execLogTailWriter is an [io.Writer] keeping the last [execLogMaxStderr] bytes.
+type execLogTailWriter struct {
+	// data contains the bytes we kept.
+	data []byte
+
+	// truncated indicates that we discarded some bytes.
+	truncated bool
+}
+
+// Write implements [io.Writer].
+func (w *execLogTailWriter) Write(data []byte) (int, error) {
+	w.data = append(w.data, data...)
+	if excess := len(w.data) - execLogMaxStderr; excess > 0 {
+		w.data = append([]byte(nil), w.data[excess:]...)
+		w.truncated = true
+	}
+	return len(data), nil
+}
+
+// addLogFileFlag adds the `--log-file` flag to the given [*nflag.FlagSet].
+func addLogFileFlag(fset *nflag.FlagSet) *string {
+	return fset.String("log-file", 0, "Append a JSON line describing each executed subprocess to the given file.")
+}
+
+// honourLogFileFlag replaces the environment with a [*execLogEnviron] using the
+// file specified by the `--log-file` flag, which is relative to the current
+// directory. When the flag is empty, the [*execLogEnviron] does not log until
+// [readConfig] provides the `log_file` setting through [honourLogFileSetting].
+func honourLogFileFlag(args *clip.CommandArgs[environ], logFile string) {
+	root, err := args.Env.AbsFilepath(defaultDotDir(args.Env).rootDir())
+	if err != nil {
+		return
+	}
+
+	// Make sure the path does not depend on the subprocesses working directory
+	if logFile != "" {
+		logFile, err = args.Env.AbsFilepath(logFile)
+		if err != nil {
+			return
+		}
+	}
+	args.Env = newExecLogEnviron(args.Env, logFile, root)
+}
+
+// honourLogFileSetting configures the [*execLogEnviron] wrapped by the given
+// environment, if any, to log to the file specified by the `log_file` setting,
+// which is relative to the multirepo root, unless the `--log-file` flag already
+// specified a log file. Because [readConfig] calls this function, we read the
+// setting under the lock that the command holds to read its configuration.
+func honourLogFileSetting(env environ, cfg *config) {
+	el, ok := unwrapPrintingEnviron(env).(*execLogEnviron)
+	if !ok || cfg.Effective.LogFile == "" {
+		return
+	}
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if el.filename == "" {
+		el.filename = cfg.Effective.LogFile
+		if !filepath.IsAbs(el.filename) {
+			el.filename = filepath.Join(el.root, el.filename)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// execlog_test.go - Tests for the structured log of the executed subprocesses.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExecLogCaptureStderr(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	t.Run("shared stdout and stderr", func(t *testing.T) {
+		var output bytes.Buffer
+		tail := &execLogTailWriter{}
+		cmd := exec.Command("sh", "-c", "for i in 1 2 3; do echo out; echo err >&2; done")
+		cmd.Stdout, cmd.Stderr = &output, &output
+		execLogCaptureStderr(cmd, tail)
+		if cmd.Stdout != cmd.Stderr {
+			t.Fatal("expected stdout and stderr to still be the same writer")
+		}
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+		expect := strings.Repeat("out\nerr\n", 3)
+		if got := output.String(); got != expect {
+			t.Fatalf("expected %q, got %q", expect, got)
+		}
+		if got := string(tail.data); got != expect {
+			t.Fatalf("expected tail %q, got %q", expect, got)
+		}
+	})
+
+	t.Run("distinct stdout and stderr", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		tail := &execLogTailWriter{}
+		cmd := exec.Command("sh", "-c", "echo out; echo err >&2")
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		execLogCaptureStderr(cmd, tail)
+		if cmd.Stdout != &stdout {
+			t.Fatal("expected stdout to be unchanged")
+		}
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != "out\n" || stderr.String() != "err\n" || string(tail.data) != "err\n" {
+			t.Fatalf("unexpected output: %q %q %q", stdout.String(), stderr.String(), tail.data)
+		}
+	})
+
+	t.Run("nil stderr", func(t *testing.T) {
+		tail := &execLogTailWriter{}
+		cmd := exec.Command("sh", "-c", "echo err >&2")
+		execLogCaptureStderr(cmd, tail)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if string(tail.data) != "err\n" {
+			t.Fatalf("unexpected tail: %q", tail.data)
+		}
+	})
+}
+
+func TestExecLogTailWriter(t *testing.T) {
+	tail := &execLogTailWriter{}
+	tail.Write(bytes.Repeat([]byte("a"), execLogMaxStderr))
+	if tail.truncated {
+		t.Fatal("did not expect truncation")
+	}
+	tail.Write([]byte("bc"))
+	if !tail.truncated || len(tail.data) != execLogMaxStderr || !bytes.HasSuffix(tail.data, []byte("abc")) {
+		t.Fatalf("unexpected tail state: truncated=%v len=%d", tail.truncated, len(tail.data))
+	}
+}
@@ -0,0 +1,52 @@
+// foreachfailed.go - Repositories where foreach failed.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// foreachFailed describes the repositories where the most recent `foreach`
+// invocation failed, which `foreach --rerun-failed` executes again.
+type foreachFailed struct {
+	// Argv contains the command executed in each repository.
+	Argv []string `json:"argv"`
+
+	// Time is when the invocation completed.
+	Time time.Time `json:"time"`
+
+	// Repos contains the names of the repositories where the command failed.
+	Repos []string `json:"repos"`
+}
+
+// readForeachFailed reads the failed repositories from the given file. A nonexistent
+// file is equivalent to a previous invocation where no repository failed.
+func readForeachFailed(env environ, filename string) (*foreachFailed, error) {
+	// check whether the file exists
+	exists, err := env.FileExists(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return &foreachFailed{Argv: []string{}, Repos: []string{}}, nil
+	}
+
+	// read and parse the file
+	data, err := env.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var failed foreachFailed
+	if err := json.Unmarshal(data, &failed); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &failed, nil
+}
+
+// writeForeachFailed writes the failed repositories to the given file.
+func writeForeachFailed(env environ, filename string, failed *foreachFailed) error {
+	return env.WriteFile(filename, append(mustMarshalIndentJSON(failed, "", "  "), '\n'), 0644)
+}
@@ -0,0 +1,115 @@
+// foreachpager.go - Paging the combined output of foreach.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/mattn/go-isatty"
+)
+
+// foreachPager collects the output of the commands executed in each repository,
+// preceded by a header naming the repository, to page it all at once.
+//
+// The zero value is ready to use.
+type foreachPager struct {
+	// buf contains the collected output.
+	buf bytes.Buffer
+}
+
+// Add adds the output of the command executed in the given repository.
+func (p *foreachPager) Add(target foreachTarget, output []byte) {
+	header := newNilSafeLipglossStyle()
+	if p.buf.Len() > 0 {
+		p.buf.WriteString("\n")
+	}
+	mustFprintf(&p.buf, "%s\n", header.Renderf("==> %s (%d/%d)", target.Name, target.Index+1, target.Count))
+	p.buf.Write(output)
+}
+
+// Run pipes the collected output through the given pager. Like git, we set
+// `LESS=FRX` and `LV=-c`, unless already set, such that less and lv interpret
+// the colors and do not page when the output fits the screen. If we cannot
+// start the pager, we write the collected output directly.
+func (p *foreachPager) Run(ctx context.Context, env environ, pager []string) error {
+	environ := env.Environ()
+	if _, found := env.LookupEnv("LESS"); !found {
+		environ = append(environ, "LESS=FRX")
+	}
+	if _, found := env.LookupEnv("LV"); !found {
+		environ = append(environ, "LV=-c")
+	}
+	cmd := exec.CommandContext(ctx, pager[0], pager[1:]...)
+	cmd.Stdin = bytes.NewReader(p.buf.Bytes())
+	cmd.Stdout = env.Stdout()
+	cmd.Stderr = env.Stderr()
+	cmd.Env = environ
+	err := env.RunCommand(cmd)
+	if errors.Is(err, exec.ErrNotFound) {
+		_, err = env.Stdout().Write(p.buf.Bytes())
+	}
+	return err
+}
+
+// foreachPagerCommand returns the pager command line, which we obtain from the
+// `MULTIREPO_PAGER` or `PAGER` environment variables and defaults to `less`.
+// Like git, we return an empty command line when the pager is empty or `cat`,
+// meaning that we should not page the output.
+func foreachPagerCommand(env environ) ([]string, error) {
+	pager := "less"
+	if value, found := env.LookupEnv("MULTIREPO_PAGER"); found {
+		pager = value
+	} else if value, found := env.LookupEnv("PAGER"); found {
+		pager = value
+	}
+	argv, err := shellquote.Split(pager)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pager %q: %w", pager, err)
+	}
+	if len(argv) <= 0 || (len(argv) == 1 && argv[0] == "cat") {
+		return nil, nil
+	}
+	return argv, nil
+}
+
+// isTerminal returns whether the given writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	filep, good := w.(*os.File)
+	return good && (isatty.IsTerminal(filep.Fd()) || isatty.IsCygwinTerminal(filep.Fd()))
+}
+
+// foreachOutput is an [io.Writer] collecting the output of a command, where
+// we serialize writes because the stdout and stderr of the command may write
+// concurrently (e.g., when we also capture the output for the report).
+//
+// The zero value is ready to use.
+type foreachOutput struct {
+	// buf contains the collected output.
+	buf bytes.Buffer
+
+	// mu serializes access to buf.
+	mu sync.Mutex
+}
+
+// Write implements [io.Writer].
+func (w *foreachOutput) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(data)
+}
+
+// Bytes returns the collected output.
+func (w *foreachOutput) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Bytes()
+}
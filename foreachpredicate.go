@@ -0,0 +1,136 @@
+// foreachpredicate.go - Predicates selecting where foreach executes.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// foreachPredicates contains the conditions that a repository must
+// satisfy for `foreach` to execute the command therein.
+type foreachPredicates struct {
+	// Ahead requires the current branch to be ahead of its upstream.
+	Ahead bool
+
+	// Behind requires the current branch to be behind its upstream.
+	Behind bool
+
+	// Branch requires the current branch to have the given name.
+	Branch string
+
+	// Clean requires the working tree to be clean.
+	Clean bool
+
+	// Dirty requires the working tree to be dirty.
+	Dirty bool
+
+	// File requires the given file or directory to exist.
+	File string
+
+	// Test requires the given shell snippet to succeed.
+	Test string
+}
+
+// AddFlags adds the `--if-*` flags to the given [*nflag.FlagSet].
+func (p *foreachPredicates) AddFlags(fset *nflag.FlagSet) {
+	fset.BoolVar(&p.Ahead, "if-ahead", 0, "Only execute where the current branch is ahead of its upstream.")
+	fset.BoolVar(&p.Behind, "if-behind", 0, "Only execute where the current branch is behind its upstream.")
+	fset.StringVar(&p.Branch, "if-branch", 0, "Only execute where the current branch has the given name.")
+	fset.BoolVar(&p.Clean, "if-clean", 0, "Only execute where the working tree is clean.")
+	fset.BoolVar(&p.Dirty, "if-dirty", 0, "Only execute where the working tree is dirty.")
+	fset.StringVar(&p.File, "if-file", 0, "Only execute where the given file or directory exists.")
+	fset.StringVar(&p.Test, "if", 0, "Only execute where the given shell snippet succeeds.")
+}
+
+// Evaluate evaluates the predicates in the given repository, returning an empty
+// string if the repository satisfies all of them and otherwise the reason why we
+// should skip it. We run the `--if` snippet using the given environment and shell.
+// Since predicates do not modify the state, we run them using [environ.RunQueryCommand],
+// such that they also run in dry-run mode.
+func (p *foreachPredicates) Evaluate(ctx context.Context,
+	env environ, dd dotDir, repo string, environ []string, shell string) (string, error) {
+	// Check whether the file or directory exists
+	if p.File != "" {
+		sbuf, err := env.Stat(filepath.Join(dd.repoPath(repo), p.File))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Sprintf("%s does not exist", p.File), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if !sbuf.Mode().IsRegular() && !sbuf.IsDir() {
+			return fmt.Sprintf("%s is neither a file nor a directory", p.File), nil
+		}
+	}
+
+	// Check whether the working tree is clean or dirty
+	if p.Clean || p.Dirty {
+		status, err := dd.probeRepoGit(ctx, env, repo, "status", "--porcelain")
+		if err != nil {
+			return "", err
+		}
+		switch dirty := status != ""; {
+		case p.Clean && dirty:
+			return "the working tree is dirty", nil
+		case p.Dirty && !dirty:
+			return "the working tree is clean", nil
+		}
+	}
+
+	// Check the current branch
+	if p.Branch != "" {
+		if dd.currentBranch(ctx, env, repo) != p.Branch {
+			return fmt.Sprintf("the current branch is not %s", p.Branch), nil
+		}
+	}
+
+	// Check whether the current branch is ahead or behind its upstream
+	if p.Ahead || p.Behind {
+		counts, err := dd.probeRepoGit(ctx, env, repo, "rev-list", "--left-right", "--count", "@{upstream}...HEAD")
+		if err != nil {
+			return "the current branch has no upstream", nil
+		}
+		fields := strings.Fields(counts)
+		if len(fields) != 2 {
+			return "", fmt.Errorf("unexpected git rev-list output: %q", counts)
+		}
+		behind, _ := strconv.Atoi(fields[0])
+		ahead, _ := strconv.Atoi(fields[1])
+		switch {
+		case p.Ahead && ahead <= 0:
+			return "the current branch is not ahead of its upstream", nil
+		case p.Behind && behind <= 0:
+			return "the current branch is not behind its upstream", nil
+		}
+	}
+
+	// Check whether the shell snippet succeeds
+	if p.Test != "" {
+		cmd := exec.CommandContext(ctx, shell, "-c", p.Test)
+		cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
+		cmd.Stdout = io.Discard
+		cmd.Stderr = env.Stderr()
+		cmd.Dir = dd.repoPath(repo)
+		cmd.Env = environ
+		if err := env.RunQueryCommand(cmd); err != nil {
+			if commandExitCode(err) > 0 {
+				return fmt.Sprintf("%s failed", p.Test), nil
+			}
+			return "", err
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,149 @@
+// foreachpredicate_test.go - Tests for the predicates selecting where foreach executes.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForeachPredicatesFile(t *testing.T) {
+	dd := dotDir(filepath.Join(t.TempDir(), dotDirName))
+	dir := dd.repoPath("repo")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file   string
+		expect string
+	}{
+		{file: "go.mod", expect: ""},
+		{file: "docs", expect: ""},
+		{file: "Makefile", expect: "Makefile does not exist"},
+	}
+	env := newStdlibEnviron()
+	for _, tc := range cases {
+		t.Run(tc.file, func(t *testing.T) {
+			p := &foreachPredicates{File: tc.file}
+			reason, err := p.Evaluate(context.Background(), env, dd, "repo", os.Environ(), "sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != tc.expect {
+				t.Fatalf("expected %q, got %q", tc.expect, reason)
+			}
+		})
+	}
+}
+
+func TestForeachPredicatesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	// Create a repository on the main branch with a single commit
+	dd := dotDir(filepath.Join(t.TempDir(), dotDirName))
+	dir := dd.repoPath("repo")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com",
+			"GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, output)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "README")
+	git("commit", "-q", "-m", "initial")
+
+	evaluate := func(p *foreachPredicates) string {
+		t.Helper()
+		reason, err := p.Evaluate(context.Background(), newStdlibEnviron(), dd, "repo", os.Environ(), "sh")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reason
+	}
+
+	t.Run("clean", func(t *testing.T) {
+		if got := evaluate(&foreachPredicates{Clean: true}); got != "" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+		if got := evaluate(&foreachPredicates{Dirty: true}); got != "the working tree is clean" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+	})
+
+	t.Run("branch", func(t *testing.T) {
+		if got := evaluate(&foreachPredicates{Branch: "main"}); got != "" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+		if got := evaluate(&foreachPredicates{Branch: "dev"}); got != "the current branch is not dev" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+	})
+
+	t.Run("upstream", func(t *testing.T) {
+		if got := evaluate(&foreachPredicates{Ahead: true}); got != "the current branch has no upstream" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+	})
+
+	t.Run("test", func(t *testing.T) {
+		if got := evaluate(&foreachPredicates{Test: "test -f README"}); got != "" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+		if got := evaluate(&foreachPredicates{Test: "test -f Makefile"}); got != "test -f Makefile failed" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+	})
+
+	t.Run("print commands", func(t *testing.T) {
+		var stderr bytes.Buffer
+		env := newXLogEnviron(&captureEnviron{environ: newStdlibEnviron(), stderr: &stderr})
+		p := &foreachPredicates{Dirty: true}
+		if _, err := p.Evaluate(context.Background(), env, dd, "repo", os.Environ(), "sh"); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(stderr.String(), "git status --porcelain") {
+			t.Fatalf("expected the query to be printed, got %q", stderr.String())
+		}
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte("changed\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := evaluate(&foreachPredicates{Dirty: true}); got != "" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+		if got := evaluate(&foreachPredicates{Clean: true}); got != "the working tree is dirty" {
+			t.Fatalf("unexpected reason: %q", got)
+		}
+	})
+}
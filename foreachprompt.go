@@ -0,0 +1,100 @@
+// foreachprompt.go - Prompting the user in interactive foreach mode.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// errForeachAborted indicates that the user aborted the interactive execution.
+var errForeachAborted = errors.New("aborted")
+
+// foreachPromptSkipReason is the skip reason of the repositories the user skipped.
+const foreachPromptSkipReason = "skipped by the user"
+
+// prompt prints a header describing the given repository and asks the user whether
+// to execute the command therein. We return the skip reason when the user skips the
+// repository and [errForeachAborted] when the user aborts, when the standard input
+// is closed, or when we have been interrupted (see [cmdForeachRunner.watchInterrupts]).
+func (c *cmdForeachRunner) prompt(ctx context.Context,
+	env environ, interrupted *atomic.Bool, target foreachTarget) (string, error) {
+	header := newNilSafeLipglossStyle()
+	mustFprintf(env.Stderr(), "\n%s\n", header.Renderf("==> %s (%d/%d)", target.Name, target.Index+1, target.Count))
+	for {
+		mustFprintf(env.Stderr(), "Execute in %s? [Y]es, [n]o (skip), [q]uit: ", target.Name)
+		answer, err := c.readAnswer(ctx, env, interrupted)
+		if err != nil {
+			mustFprintf(env.Stderr(), "\n")
+			return "", err
+		}
+		switch strings.ToLower(answer) {
+		case "", "y", "yes":
+			return "", nil
+		case "n", "no", "s", "skip":
+			return foreachPromptSkipReason, nil
+		case "q", "quit", "a", "abort":
+			return "", errForeachAborted
+		}
+	}
+}
+
+// readAnswer reads a line from the standard input. We read in a background
+// goroutine, such that we stop waiting when we have been interrupted.
+func (c *cmdForeachRunner) readAnswer(ctx context.Context, env environ, interrupted *atomic.Bool) (string, error) {
+	answers := make(chan string, 1)
+	errch := make(chan error, 1)
+	go func() {
+		answer, err := readLineUnbuffered(env.Stdin())
+		if err != nil {
+			errch <- err
+			return
+		}
+		answers <- answer
+	}()
+
+	// Note: we poll the interrupted flag, which the first interrupt sets
+	// without cancelling the context, and the second interrupt cancels
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case answer := <-answers:
+			return strings.TrimSpace(answer), nil
+		case <-errch:
+			return "", errForeachAborted
+		case <-ctx.Done():
+			return "", errForeachAborted
+		case <-ticker.C:
+			if interrupted.Load() {
+				return "", errForeachAborted
+			}
+		}
+	}
+}
+
+// readLineUnbuffered reads a line from the given reader one byte at a time, such
+// that we do not consume the input meant for the commands we execute afterwards.
+func readLineUnbuffered(r io.Reader) (string, error) {
+	var (
+		line []byte
+		buf  [1]byte
+	)
+	for {
+		count, err := r.Read(buf[:])
+		if count > 0 {
+			if buf[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
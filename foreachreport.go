@@ -0,0 +1,242 @@
+// foreachreport.go - Machine-readable reports of foreach.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/kballard/go-shellquote"
+)
+
+// foreachReportFormats contains the supported report formats.
+var foreachReportFormats = []string{"json", "junit", "tap"}
+
+// foreachReport is the machine-readable report of a `foreach` invocation,
+// containing a test case for each repository.
+type foreachReport struct {
+	// Argv contains the command executed in each repository.
+	Argv []string
+
+	// Start is when the invocation started.
+	Start time.Time
+
+	// Duration is the invocation duration.
+	Duration time.Duration
+
+	// Results contains the results of the executed commands.
+	Results []foreachResult
+
+	// Skipped contains the repositories we did not reach because
+	// we stopped at the first failure or we have been interrupted.
+	Skipped []string
+}
+
+// foreachReportCase is a test case of a [*foreachReport].
+type foreachReportCase struct {
+	// Repo is the repository name.
+	Repo string `json:"repo"`
+
+	// Status is either "passed", "failed", "timeout", "interrupted", or "skipped".
+	Status string `json:"status"`
+
+	// ExitCode is the exit code (see [commandExitCode]).
+	ExitCode int `json:"exit_code"`
+
+	// Error is the error that occurred, if any.
+	Error string `json:"error,omitempty"`
+
+	// SkipReason explains why we skipped the repository, if we did.
+	SkipReason string `json:"skip_reason,omitempty"`
+
+	// DurationMs is the duration in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+
+	// Stdout contains the captured standard output.
+	Stdout string `json:"stdout"`
+
+	// Stderr contains the captured standard error.
+	Stderr string `json:"stderr"`
+}
+
+// Cases returns the test cases, including the skipped ones.
+func (r *foreachReport) Cases() []foreachReportCase {
+	cases := []foreachReportCase{}
+	for _, result := range r.Results {
+		tc := foreachReportCase{
+			Repo:       result.Repo,
+			Status:     result.Status(),
+			ExitCode:   commandExitCode(result.Err),
+			SkipReason: result.SkipReason,
+			DurationMs: result.Duration.Milliseconds(),
+			Stdout:     string(result.Stdout),
+			Stderr:     string(result.Stderr),
+		}
+		if result.Err != nil {
+			tc.Error = result.Err.Error()
+		}
+		cases = append(cases, tc)
+	}
+	for _, repo := range r.Skipped {
+		cases = append(cases, foreachReportCase{
+			Repo:       repo,
+			Status:     "skipped",
+			ExitCode:   -1,
+			SkipReason: "not executed",
+		})
+	}
+	return cases
+}
+
+// Marshal serializes the report using the given format.
+func (r *foreachReport) Marshal(format string) []byte {
+	switch format {
+	case "json":
+		return r.marshalJSON()
+	case "junit":
+		return r.marshalJUnit()
+	case "tap":
+		return r.marshalTAP()
+	default:
+		panic(fmt.Sprintf("unsupported report format: %s", format))
+	}
+}
+
+// marshalJSON serializes the report as JSON.
+func (r *foreachReport) marshalJSON() []byte {
+	report := struct {
+		Argv       []string            `json:"argv"`
+		Start      time.Time           `json:"start"`
+		DurationMs int64               `json:"duration_ms"`
+		Cases      []foreachReportCase `json:"cases"`
+	}{r.Argv, r.Start.UTC(), r.Duration.Milliseconds(), r.Cases()}
+	return append(mustMarshalIndentJSON(report, "", "  "), '\n')
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is a test suite of a JUnit XML report.
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a test case of a JUnit XML report.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+// junitMessage is a failure, error, or skipped element of a JUnit XML report.
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// marshalJUnit serializes the report as JUnit XML, where we represent timeouts and
+// interrupts as errors, to distinguish them from the commands that failed.
+func (r *foreachReport) marshalJUnit() []byte {
+	suite := junitTestSuite{
+		Name:      shellquote.Join(append([]string{"multirepo", "foreach"}, r.Argv...)...),
+		Time:      junitSeconds(r.Duration),
+		Timestamp: r.Start.UTC().Format(time.RFC3339),
+	}
+	for _, tc := range r.Cases() {
+		jtc := junitTestCase{
+			Name:      tc.Repo,
+			ClassName: "multirepo",
+			Time:      junitSeconds(time.Duration(tc.DurationMs) * time.Millisecond),
+			SystemOut: tc.Stdout,
+			SystemErr: tc.Stderr,
+		}
+		switch tc.Status {
+		case "failed":
+			jtc.Failure = &junitMessage{Message: tc.Error}
+			suite.Failures++
+		case "timeout", "interrupted":
+			jtc.Error = &junitMessage{Message: tc.Error}
+			suite.Errors++
+		case "skipped":
+			jtc.Skipped = &junitMessage{Message: tc.SkipReason}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, jtc)
+		suite.Tests++
+	}
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	assert.NotError(err)
+	return append(append([]byte(xml.Header), data...), '\n')
+}
+
+// junitSeconds formats the given duration as seconds for JUnit XML.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// marshalTAP serializes the report using TAP version 13, where we use a
+// YAML diagnostic block to describe each executed command.
+func (r *foreachReport) marshalTAP() []byte {
+	var buf bytes.Buffer
+	cases := r.Cases()
+	mustFprintf(&buf, "TAP version 13\n1..%d\n", len(cases))
+	for idx, tc := range cases {
+		switch tc.Status {
+		case "skipped":
+			mustFprintf(&buf, "ok %d - %s # SKIP %s\n", idx+1, tc.Repo, tc.SkipReason)
+			continue
+		case "passed":
+			mustFprintf(&buf, "ok %d - %s\n", idx+1, tc.Repo)
+		default:
+			mustFprintf(&buf, "not ok %d - %s\n", idx+1, tc.Repo)
+		}
+		mustFprintf(&buf, "  ---\n")
+		mustFprintf(&buf, "  status: %s\n", tc.Status)
+		mustFprintf(&buf, "  exit_code: %d\n", tc.ExitCode)
+		mustFprintf(&buf, "  duration_ms: %d\n", tc.DurationMs)
+		tapWriteBlock(&buf, "stdout", tc.Stdout)
+		tapWriteBlock(&buf, "stderr", tc.Stderr)
+		mustFprintf(&buf, "  ...\n")
+	}
+	return buf.Bytes()
+}
+
+// tapWriteBlock writes the given output as a YAML literal block. We use an
+// explicit indentation indicator because, otherwise, YAML would infer the
+// indentation from the first line, thus breaking when the output starts
+// with spaces, and we strip carriage returns, which YAML treats as line
+// breaks, such that the block contains the same lines as the output.
+func tapWriteBlock(buf *bytes.Buffer, key, value string) {
+	value = strings.ReplaceAll(value, "\r", "")
+	if value == "" {
+		mustFprintf(buf, "  %s: ''\n", key)
+		return
+	}
+	chomping := ""
+	if !strings.HasSuffix(value, "\n") {
+		chomping = "-"
+	}
+	mustFprintf(buf, "  %s: |2%s\n", key, chomping)
+	for _, line := range strings.Split(strings.TrimSuffix(value, "\n"), "\n") {
+		mustFprintf(buf, "    %s\n", line)
+	}
+}
@@ -0,0 +1,49 @@
+// foreachreport_test.go - Tests for the machine-readable foreach reports.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestForeachReportMarshalTAP(t *testing.T) {
+	report := &foreachReport{
+		Argv: []string{"make", "test"},
+		Results: []foreachResult{{
+			Repo:   "r1",
+			Stdout: []byte("  indented\r\n\tsecond\r\n"),
+		}, {
+			Repo:   "r2",
+			Err:    errors.New("failed"),
+			Stderr: []byte("    deeply indented"),
+		}},
+		Skipped: []string{"r3"},
+	}
+	expect := "TAP version 13\n" +
+		"1..3\n" +
+		"ok 1 - r1\n" +
+		"  ---\n" +
+		"  status: passed\n" +
+		"  exit_code: 0\n" +
+		"  duration_ms: 0\n" +
+		"  stdout: |2\n" +
+		"      indented\n" +
+		"    \tsecond\n" +
+		"  stderr: ''\n" +
+		"  ...\n" +
+		"not ok 2 - r2\n" +
+		"  ---\n" +
+		"  status: failed\n" +
+		"  exit_code: -1\n" +
+		"  duration_ms: 0\n" +
+		"  stdout: ''\n" +
+		"  stderr: |2-\n" +
+		"        deeply indented\n" +
+		"  ...\n" +
+		"ok 3 - r3 # SKIP not executed\n"
+	if got := string(report.Marshal("tap")); got != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, got)
+	}
+}
@@ -34,3 +34,26 @@ func fsxFileExists(path string) (bool, error) {
 	// Handle the successful case
 	return true, nil
 }
+
+// fsxDirExists checks if a file exists and is a directory.
+func fsxDirExists(path string) (bool, error) {
+	// Get information about the file
+	sbuf, err := os.Stat(path)
+
+	// Handle the error case distinguishing between the file
+	// not existing and other kinds of errors
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return true, err
+	}
+
+	// Handle the case where the file is not a directory
+	if !sbuf.IsDir() {
+		return false, errUnexpectedFileType
+	}
+
+	// Handle the successful case
+	return true, nil
+}
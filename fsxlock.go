@@ -0,0 +1,37 @@
+// fsxlock.go - File-system locking extensions.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// errLockBusy indicates that another process holds a conflicting lock.
+var errLockBusy = errors.New("lock held by another process")
+
+// fsxLockFile opens or creates the file at the given path and locks it. When
+// shared is true, we acquire a shared (read) lock, otherwise an exclusive (write)
+// lock. When wait is false and another process holds a conflicting lock, we fail
+// immediately with [errLockBusy] instead of waiting for the lock to be released.
+func fsxLockFile(path string, shared, wait bool) (lockReleaser, error) {
+	// Open or create the lock file
+	filep, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	// Acquire the lock using the platform specific code
+	if err := fsxLock(filep, shared, wait); err != nil {
+		filep.Close()
+		return nil, err
+	}
+
+	// Return a function that releases the lock
+	release := func() {
+		fsxUnlock(filep)
+		filep.Close()
+	}
+	return release, nil
+}
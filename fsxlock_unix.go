@@ -0,0 +1,41 @@
+//go:build unix
+
+// fsxlock_unix.go - Unix-specific file-system locking code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fsxLock locks the given file using flock(2).
+func fsxLock(filep *os.File, shared, wait bool) error {
+	how := syscall.LOCK_EX
+	if shared {
+		how = syscall.LOCK_SH
+	}
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(filep.Fd()), how)
+		switch {
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return errLockBusy
+		case err != nil:
+			return &os.PathError{Op: "flock", Path: filep.Name(), Err: err}
+		default:
+			return nil
+		}
+	}
+}
+
+// fsxUnlock unlocks the given file using flock(2).
+func fsxUnlock(filep *os.File) error {
+	return syscall.Flock(int(filep.Fd()), syscall.LOCK_UN)
+}
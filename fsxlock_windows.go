@@ -0,0 +1,40 @@
+//go:build windows
+
+// fsxlock_windows.go - Windows-specific file-system locking code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fsxLock locks the whole given file using LockFileEx.
+func fsxLock(filep *os.File, shared, wait bool) error {
+	var flags uint32
+	if !shared {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !wait {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	overlapped := new(windows.Overlapped)
+	err := windows.LockFileEx(windows.Handle(filep.Fd()), flags, 0, ^uint32(0), ^uint32(0), overlapped)
+	switch {
+	case errors.Is(err, windows.ERROR_LOCK_VIOLATION):
+		return errLockBusy
+	case err != nil:
+		return &os.PathError{Op: "LockFileEx", Path: filep.Name(), Err: err}
+	default:
+		return nil
+	}
+}
+
+// fsxUnlock unlocks the whole given file using UnlockFileEx.
+func fsxUnlock(filep *os.File) error {
+	overlapped := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(filep.Fd()), 0, ^uint32(0), ^uint32(0), overlapped)
+}
@@ -4,11 +4,11 @@ go 1.24.0
 
 require github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 
-require github.com/rogpeppe/go-internal v1.14.1
-
 require (
 	github.com/bassosimone/clip v0.7.0
 	github.com/charmbracelet/lipgloss v1.1.0
+	github.com/mattn/go-isatty v0.0.20
+	golang.org/x/sys v0.32.0
 )
 
 require (
@@ -18,10 +18,8 @@ require (
 	github.com/charmbracelet/x/cellbuf v0.0.13-0.20250311204145-2c3ea96c31dd // indirect
 	github.com/charmbracelet/x/term v0.2.1 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/muesli/termenv v0.16.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/sys v0.32.0 // indirect
 )
@@ -0,0 +1,154 @@
+// journal.go - Journal of the index mutations.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// journalEntry is an entry of the journal of index mutations.
+type journalEntry struct {
+	// Argv contains the command line that mutated the index.
+	Argv []string `json:"argv"`
+
+	// Time is when the mutation occurred.
+	Time time.Time `json:"time"`
+
+	// Before contains the repositories before the mutation.
+	Before map[string]repoInfo `json:"before"`
+
+	// After contains the repositories after the mutation.
+	After map[string]repoInfo `json:"after"`
+}
+
+// Diff returns the lines describing the difference between the
+// repositories before and after the mutation, sorted by name.
+func (je *journalEntry) Diff() []string {
+	all := map[string]repoInfo{}
+	maps.Copy(all, je.Before)
+	maps.Copy(all, je.After)
+
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(all)) {
+		before, inBefore := je.Before[name]
+		after, inAfter := je.After[name]
+		switch {
+		case inBefore && !inAfter:
+			lines = append(lines, fmt.Sprintf("- %s %s", name, before))
+		case !inBefore && inAfter:
+			lines = append(lines, fmt.Sprintf("+ %s %s", name, after))
+		case !before.Equal(after):
+			lines = append(lines, fmt.Sprintf("- %s %s", name, before))
+			lines = append(lines, fmt.Sprintf("+ %s %s", name, after))
+		}
+	}
+	return lines
+}
+
+// readJournal reads the journal from the given file. A nonexistent
+// journal file is equivalent to an empty journal.
+func readJournal(env environ, filename string) ([]journalEntry, error) {
+	// check whether the file exists
+	exists, err := env.FileExists(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []journalEntry{}, nil
+	}
+
+	// read the file from the disk
+	data, err := env.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse each line as a JSON entry
+	entries := []journalEntry{}
+	for lineno, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineno+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// writeJournal writes the journal to the given file.
+func writeJournal(env environ, filename string, entries []journalEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return env.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// errJournalMismatch indicates that the configuration does not match the
+// journal, typically because someone edited `config.json` manually.
+var errJournalMismatch = errors.New("config.json has been modified outside of multirepo")
+
+// recordMutation records in the journal the mutation from the before to
+// the after configuration attributing it to the given command line. No-op
+// mutations are not recorded. You MUST only invoke this function when the
+// `.multirepo` directory has been locked.
+func (dd dotDir) recordMutation(env environ, argv []string, before, after *config) error {
+	// Avoid journaling no-op mutations
+	if maps.EqualFunc(before.Repos, after.Repos, repoInfo.Equal) {
+		return nil
+	}
+
+	// Append the mutation to the journal
+	data, err := json.Marshal(journalEntry{
+		Argv:   argv,
+		Time:   time.Now().UTC(),
+		Before: maps.Clone(before.Repos),
+		After:  maps.Clone(after.Repos),
+	})
+	if err != nil {
+		return err
+	}
+	return env.AppendFile(dd.journalFilePath(), append(data, '\n'), 0644)
+}
+
+// saveConfig writes the after configuration to disk, records the mutation
+// from the before configuration in the journal and, if the `.multirepo`
+// directory is tracked using git, commits the change. You MUST only invoke
+// this function when the `.multirepo` directory has been locked.
+func (dd dotDir) saveConfig(ctx context.Context, env environ,
+	argv []string, before, after *config) error {
+	// Write the configuration file
+	if err := after.WriteFile(env, dd.configFilePath()); err != nil {
+		return err
+	}
+
+	// Append the mutation to the journal
+	if err := dd.recordMutation(env, argv, before, after); err != nil {
+		return err
+	}
+
+	// Commit the mutation if the manifest is tracked
+	return dd.commitManifest(ctx, env, argv, before, after)
+}
+
+// journalArgv returns the command line to record in the journal.
+func journalArgv(env environ) []string {
+	return append([]string{"multirepo"}, env.Args()[1:]...)
+}
@@ -0,0 +1,41 @@
+// journal_test.go - Tests for the journal of the index mutations.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJournalEntryDiff(t *testing.T) {
+	entry := &journalEntry{
+		Before: map[string]repoInfo{
+			"removed":   {URL: "git@github.com:ooni/removed"},
+			"unchanged": {URL: "git@github.com:ooni/unchanged", Tags: []string{"go"}},
+			"moved":     {URL: "git@github.com:ooni/old"},
+			"tagged":    {URL: "git@github.com:ooni/tagged"},
+		},
+		After: map[string]repoInfo{
+			"added":     {URL: "git@github.com:ooni/added", Tags: []string{"go", "cli"}},
+			"unchanged": {URL: "git@github.com:ooni/unchanged", Tags: []string{"go"}},
+			"moved":     {URL: "git@github.com:ooni/new"},
+			"tagged":    {URL: "git@github.com:ooni/tagged", Tags: []string{"cli"}},
+		},
+	}
+	expect := []string{
+		"+ added git@github.com:ooni/added [go,cli]",
+		"- moved git@github.com:ooni/old",
+		"+ moved git@github.com:ooni/new",
+		"- removed git@github.com:ooni/removed",
+		"- tagged git@github.com:ooni/tagged",
+		"+ tagged git@github.com:ooni/tagged [cli]",
+	}
+	if got := entry.Diff(); !slices.Equal(got, expect) {
+		t.Fatalf("expected %q, got %q", expect, got)
+	}
+
+	if got := (&journalEntry{}).Diff(); len(got) != 0 {
+		t.Fatalf("expected no lines, got %q", got)
+	}
+}
@@ -0,0 +1,118 @@
+// jsonpos.go - Locating JSON syntax elements inside a file.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// jsonLocatedError is an error occurring at a given position of a JSON file.
+type jsonLocatedError struct {
+	// Filename is the name of the file.
+	Filename string
+
+	// Line is the 1-based line number.
+	Line int
+
+	// Column is the 1-based column number.
+	Column int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (err *jsonLocatedError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", err.Filename, err.Line, err.Column, err.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (err *jsonLocatedError) Unwrap() error {
+	return err.Err
+}
+
+// newJSONLocatedError creates a [*jsonLocatedError] given the byte offset.
+func newJSONLocatedError(filename string, data []byte, offset int64, err error) *jsonLocatedError {
+	line, column := jsonLineColumn(data, offset)
+	return &jsonLocatedError{Filename: filename, Line: line, Column: column, Err: err}
+}
+
+// jsonLineColumn converts a byte offset into 1-based line and column numbers.
+func jsonLineColumn(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	prefix := data[:offset]
+	line := bytes.Count(prefix, []byte("\n")) + 1
+	column := len(prefix) - bytes.LastIndexByte(prefix, '\n')
+	return line, column
+}
+
+// jsonFindKey returns the byte offset of the first object key whose path
+// (i.e., the list of keys leading to it, including itself) matches the
+// given predicate. The boolean is false when there is no such key or
+// the data does not contain valid JSON.
+func jsonFindKey(data []byte, match func(path []string) bool) (int64, bool) {
+	// frame is a JSON object or array we're currently inside.
+	type frame struct {
+		object    bool
+		expectKey bool
+		key       string
+	}
+
+	var stack []*frame
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, false
+		}
+
+		// handle entering and leaving objects and arrays
+		if delim, good := tok.(json.Delim); good {
+			switch delim {
+			case '{', '[':
+				stack = append(stack, &frame{object: delim == '{', expectKey: delim == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 && stack[len(stack)-1].object {
+					stack[len(stack)-1].expectKey = true
+				}
+			}
+			continue
+		}
+
+		// handle scalar values
+		if len(stack) <= 0 || !stack[len(stack)-1].object {
+			continue
+		}
+		top := stack[len(stack)-1]
+		if !top.expectKey {
+			top.expectKey = true
+			continue
+		}
+
+		// handle object keys
+		top.key, top.expectKey = tok.(string), false
+		var path []string
+		for _, fx := range stack {
+			if fx.object {
+				path = append(path, fx.key)
+			}
+		}
+		if match(path) {
+			// the decoder is just past the closing quote of the key
+			end := dec.InputOffset()
+			return int64(bytes.LastIndexByte(data[:end-1], '"')), true
+		}
+	}
+}
+
+// jsonFindPath is like [jsonFindKey] but matches an exact path.
+func jsonFindPath(data []byte, path ...string) (int64, bool) {
+	return jsonFindKey(data, func(candidate []string) bool {
+		return slices.Equal(candidate, path)
+	})
+}
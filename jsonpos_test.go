@@ -0,0 +1,86 @@
+// jsonpos_test.go - Tests for locating JSON syntax elements.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJSONLineColumn(t *testing.T) {
+	data := []byte("{\n  \"a\": 1,\n  \"b\": 2\n}\n")
+	cases := []struct {
+		offset       int64
+		line, column int
+	}{
+		{offset: -1, line: 1, column: 1},
+		{offset: 0, line: 1, column: 1},
+		{offset: 1, line: 1, column: 2},
+		{offset: 2, line: 2, column: 1},
+		{offset: 4, line: 2, column: 3},
+		{offset: 14, line: 3, column: 3},
+		{offset: 1000, line: 5, column: 1},
+	}
+	for _, tc := range cases {
+		line, column := jsonLineColumn(data, tc.offset)
+		if line != tc.line || column != tc.column {
+			t.Errorf("offset %d: expected %d:%d, got %d:%d", tc.offset, tc.line, tc.column, line, column)
+		}
+	}
+}
+
+func TestJSONFindPath(t *testing.T) {
+	data := []byte(`{
+  "version": 1,
+  "repos": {
+    "a": {"url": "x", "tags": ["url"]},
+    "b": {"url": "y"}
+  },
+  "list": [{"url": "z"}]
+}`)
+	cases := []struct {
+		path         []string
+		found        bool
+		line, column int
+	}{
+		{path: []string{"version"}, found: true, line: 2, column: 3},
+		{path: []string{"repos"}, found: true, line: 3, column: 3},
+		{path: []string{"repos", "a"}, found: true, line: 4, column: 5},
+		{path: []string{"repos", "a", "tags"}, found: true, line: 4, column: 23},
+		{path: []string{"repos", "b", "url"}, found: true, line: 5, column: 11},
+		{path: []string{"list", "url"}, found: true, line: 7, column: 13},
+		{path: []string{"repos", "c"}, found: false},
+		{path: []string{"url"}, found: false},
+	}
+	for _, tc := range cases {
+		offset, found := jsonFindPath(data, tc.path...)
+		if found != tc.found {
+			t.Errorf("%v: expected found=%v, got %v", tc.path, tc.found, found)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if line, column := jsonLineColumn(data, offset); line != tc.line || column != tc.column {
+			t.Errorf("%v: expected %d:%d, got %d:%d", tc.path, tc.line, tc.column, line, column)
+		}
+	}
+}
+
+func TestJSONFindKeyInvalidJSON(t *testing.T) {
+	if _, found := jsonFindPath([]byte(`{"a": `), "b"); found {
+		t.Fatal("expected not to find keys in invalid JSON")
+	}
+}
+
+func TestJSONLocatedError(t *testing.T) {
+	inner := errors.New("mocked error")
+	err := newJSONLocatedError("config.json", []byte("{\n  \"a\": 1\n}"), 4, inner)
+	if got := err.Error(); got != "config.json:2:3: mocked error" {
+		t.Fatalf("unexpected error string: %s", got)
+	}
+	if !errors.Is(err, inner) {
+		t.Fatal("expected the error to wrap the underlying error")
+	}
+}
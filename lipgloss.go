@@ -24,6 +24,13 @@ func newNilSafeLipglossStyle() *nilSafeLipglossStyle {
 	return &nilSafeLipglossStyle{style}
 }
 
+// newNilSafeLipglossFailureStyle creates a [*nilSafeLipglossStyle] highlighting failures.
+func newNilSafeLipglossFailureStyle() *nilSafeLipglossStyle {
+	const red = "#FF0000"
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color(red)).Bold(true)
+	return &nilSafeLipglossStyle{style}
+}
+
 // Render is a nil-safe colored-text renderer.
 func (style *nilSafeLipglossStyle) Render(message string) string {
 	if style != nil {
@@ -0,0 +1,48 @@
+// lockflags.go - Command line flags controlling locking.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// lockFlags contains the flags controlling how we lock the dot directory.
+//
+// The zero value is not ready to use. Construct using [newLockFlags].
+type lockFlags struct {
+	// timeout is the value of the `--lock-timeout` flag.
+	timeout string
+
+	// noWait is the value of the `--no-wait` flag.
+	noWait bool
+}
+
+// newLockFlags adds the `--lock-timeout` and `--no-wait` flags to the given [*nflag.FlagSet].
+func newLockFlags(fset *nflag.FlagSet) *lockFlags {
+	lf := &lockFlags{}
+	fset.StringVar(&lf.timeout, "lock-timeout", 0, "Fail if the lock is not acquired within the given duration (e.g., 10s).")
+	fset.BoolVar(&lf.noWait, "no-wait", 0, "Fail immediately if another process holds the lock.")
+	return lf
+}
+
+// mustTimeout returns the lock timeout to use with [dotDir.lock]. Like
+// [nflag.ExitOnError], we print an error and exit on invalid values.
+func (lf *lockFlags) mustTimeout(args *clip.CommandArgs[environ]) time.Duration {
+	switch {
+	case lf.noWait:
+		return 0
+	case lf.timeout == "":
+		return -1
+	}
+	timeout, err := time.ParseDuration(lf.timeout)
+	if err != nil || timeout < 0 {
+		mustFprintf(args.Env.Stderr(), "%s: invalid --lock-timeout value: %q\n", args.CommandName, lf.timeout)
+		mustFprintf(args.Env.Stderr(), "Try '%s --help' for more information.\n", args.CommandName)
+		args.Env.Exit(2)
+	}
+	return timeout
+}
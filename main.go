@@ -17,9 +17,49 @@ func main() {
 		Command: &clip.DispatcherCommand[environ]{
 			BriefDescriptionText: "Manage multiple git repositories as a monorepo.",
 			Commands: map[string]clip.Command[environ]{
-				"clone":   cmdClone,
+				"clone":  cmdClone,
+				"commit": cmdCommit,
+				"config": &clip.DispatcherCommand[environ]{
+					BriefDescriptionText: "Inspect the multirepo configuration.",
+					Commands: map[string]clip.Command[environ]{
+						"show":     cmdConfigShow,
+						"validate": cmdConfigValidate,
+					},
+					ErrorHandling:             nflag.ExitOnError,
+					Version:                   Version,
+					OptionPrefixes:            []string{"--", "-"},
+					OptionsArgumentsSeparator: "--",
+				},
 				"foreach": cmdForeach,
+				"grep":    cmdGrep,
+				"history": cmdHistory,
 				"init":    cmdInit,
+				"log":     cmdLog,
+				"manifest": &clip.DispatcherCommand[environ]{
+					BriefDescriptionText: "Share the multirepo configuration using git.",
+					Commands: map[string]clip.Command[environ]{
+						"pull": cmdManifestPull,
+						"push": cmdManifestPush,
+					},
+					ErrorHandling:             nflag.ExitOnError,
+					Version:                   Version,
+					OptionPrefixes:            []string{"--", "-"},
+					OptionsArgumentsSeparator: "--",
+				},
+				"push": cmdPush,
+				"release": &clip.DispatcherCommand[environ]{
+					BriefDescriptionText: "Tag all the repositories and inspect past releases.",
+					Commands: map[string]clip.Command[environ]{
+						"ls":   cmdReleaseLs,
+						"show": cmdReleaseShow,
+						"tag":  cmdReleaseTag,
+					},
+					ErrorHandling:             nflag.ExitOnError,
+					Version:                   Version,
+					OptionPrefixes:            []string{"--", "-"},
+					OptionsArgumentsSeparator: "--",
+				},
+				"replace": cmdReplace,
 				"repo": &clip.DispatcherCommand[environ]{
 					BriefDescriptionText: "Add/remove repositories from the multirepo index.",
 					Commands: map[string]clip.Command[environ]{
@@ -32,6 +72,20 @@ func main() {
 					OptionPrefixes:            []string{"--", "-"},
 					OptionsArgumentsSeparator: "--",
 				},
+				"topic": &clip.DispatcherCommand[environ]{
+					BriefDescriptionText: "Manage topic branches spanning several repositories.",
+					Commands: map[string]clip.Command[environ]{
+						"finish": cmdTopicFinish,
+						"start":  cmdTopicStart,
+						"status": cmdTopicStatus,
+						"switch": cmdTopicSwitch,
+					},
+					ErrorHandling:             nflag.ExitOnError,
+					Version:                   Version,
+					OptionPrefixes:            []string{"--", "-"},
+					OptionsArgumentsSeparator: "--",
+				},
+				"undo": cmdUndo,
 			},
 			ErrorHandling:             nflag.ExitOnError,
 			Version:                   Version,
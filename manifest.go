@@ -0,0 +1,75 @@
+// manifest.go - Tracking the `.multirepo` directory using git.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// manifestGitignore is the `.gitignore` file of a tracked `.multirepo`
+// directory. We only track the files shared by the team, while we
+// ignore local state such as the lock file and the journal.
+const manifestGitignore = `/*
+!/.gitignore
+!/config.json
+!/releases/
+`
+
+// errNotTracked indicates that the dot directory is not tracked using git.
+var errNotTracked = errors.New("the .multirepo directory is not tracked using git (hint: use `multirepo init --track`)")
+
+// isTracked returns whether the dot directory is tracked using git.
+func (dd dotDir) isTracked(env environ) (bool, error) {
+	return env.DirExists(filepath.Join(dd.String(), ".git"))
+}
+
+// gitCommand creates a git command to execute inside the dot directory.
+func (dd dotDir) gitCommand(ctx context.Context, env environ, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
+	cmd.Stdout = env.Stdout()
+	cmd.Stderr = env.Stderr()
+	cmd.Dir = dd.String()
+	return cmd
+}
+
+// runGit executes a git command inside the dot directory.
+func (dd dotDir) runGit(ctx context.Context, env environ, args ...string) error {
+	cmd := dd.gitCommand(ctx, env, args...)
+	return env.RunCommand(cmd)
+}
+
+// commitManifest commits the changes to the configuration file caused by the
+// given command line, provided that the dot directory is tracked using git and
+// that there are changes. You MUST only invoke this function when the `.multirepo`
+// directory has been locked.
+func (dd dotDir) commitManifest(ctx context.Context, env environ,
+	argv []string, before, after *config) error {
+	// Do nothing unless the dot directory is tracked
+	tracked, err := dd.isTracked(env)
+	if err != nil || !tracked {
+		return err
+	}
+
+	// Do nothing if there are no changes to commit
+	diff := (&journalEntry{Before: before.Repos, After: after.Repos}).Diff()
+	if len(diff) <= 0 {
+		return nil
+	}
+
+	// Stage and commit describing the change
+	if err := dd.runGit(ctx, env, "add", "config.json"); err != nil {
+		return err
+	}
+	return dd.runGit(ctx, env, "commit", "-q",
+		"-m", shellquote.Join(argv...), "-m", strings.Join(diff, "\n"))
+}
@@ -16,6 +16,15 @@ func mustFprintf(w io.Writer, format string, args ...any) {
 	}
 }
 
+// mustMarshalJSON is like [json.Marshal] but panics in case of error.
+func mustMarshalJSON(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
 // mustMarshalIndentJSON is like [json.MarshalIndent] but panics in case of error.
 func mustMarshalIndentJSON(v any, prefix string, indent string) []byte {
 	data, err := json.MarshalIndent(v, prefix, indent)
@@ -0,0 +1,21 @@
+//go:build unix
+
+// procalive_unix.go - Unix-specific process liveness code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// procAlive returns whether a process with the given PID exists, where
+// EPERM means that it exists but belongs to another user.
+func procAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+// procalive_windows.go - Windows-specific process liveness code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// procStillActive is the exit code of a process that is still running.
+const procStillActive = 259
+
+// procQueryLimitedInformation is the access right to query the exit code.
+const procQueryLimitedInformation = 0x1000
+
+// procAlive returns whether a running process with the given PID exists, where
+// ERROR_ACCESS_DENIED means that it exists but belongs to another user.
+func procAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	handle, err := syscall.OpenProcess(procQueryLimitedInformation, false, uint32(pid))
+	if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == procStillActive
+}
@@ -0,0 +1,70 @@
+// procgroup.go - Running subprocesses in their own process group.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// procGroupKillGrace is the time we give to a process group to terminate
+// after asking it to terminate and before forcibly killing it.
+const procGroupKillGrace = 5 * time.Second
+
+// procGroupConfigure configures the given [*exec.Cmd], which MUST have been created
+// using [exec.CommandContext], to run in its own process group, such that, when
+// the context is done, we terminate the whole group rather than just the direct
+// child. This ensures that the processes spawned by the subcommand (e.g., the
+// helpers spawned by `git fetch`) do not outlive it. We first ask the group to
+// terminate and then, after the given grace period, forcibly kill it.
+//
+// On Unix, the process group belongs to a new session without a controlling
+// terminal, such that the terminal does not deliver interrupts to it and the
+// commands trying to prompt the user (e.g., for an SSH passphrase) fail rather
+// than stopping forever because a background process group cannot read from
+// the terminal. The caller MUST invoke the returned function after the command
+// terminates, to cancel the pending forcible kill, such that we never kill a
+// process group whose ID has been reused.
+func procGroupConfigure(cmd *exec.Cmd, grace time.Duration) func() {
+	procGroupSetup(cmd)
+	var (
+		mu     sync.Mutex
+		done   bool
+		cancel func()
+	)
+	cmd.Cancel = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return os.ErrProcessDone
+		}
+		var err error
+		cancel, err = procGroupTerminate(cmd, grace)
+		return err
+	}
+	cmd.WaitDelay = grace
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		done = true
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+// procConfigureGracefulCancel configures the given [*exec.Cmd], which MUST have been
+// created using [exec.CommandContext], such that, when the context is done, we
+// interrupt it rather than killing it, and only kill it after the given grace
+// period. This is useful for commands running in our process group that clean up
+// on interrupt (e.g., `git clone` removing the partially cloned directory). Since
+// Windows does not support [os.Interrupt], there we kill after the grace period.
+func procConfigureGracefulCancel(cmd *exec.Cmd, grace time.Duration) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = grace
+}
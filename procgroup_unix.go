@@ -0,0 +1,36 @@
+//go:build unix
+
+// procgroup_unix.go - Unix-specific process group code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// procGroupSetup makes the command the leader of a new session, and hence of
+// a new process group, without a controlling terminal.
+func procGroupSetup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+// procGroupTerminate sends SIGTERM to the process group led by the command
+// and schedules sending SIGKILL to the same group after the grace period,
+// returning the function that cancels sending SIGKILL. We also send SIGCONT,
+// such that stopped processes (e.g., because of SIGSTOP) receive SIGTERM
+// immediately.
+func procGroupTerminate(cmd *exec.Cmd, grace time.Duration) (func(), error) {
+	pgid := cmd.Process.Pid
+	timer := time.AfterFunc(grace, func() {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+	err := syscall.Kill(-pgid, syscall.SIGTERM)
+	syscall.Kill(-pgid, syscall.SIGCONT)
+	return func() { timer.Stop() }, err
+}
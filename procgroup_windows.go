@@ -0,0 +1,30 @@
+//go:build windows
+
+// procgroup_windows.go - Windows-specific process group code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// procGroupSetup makes the command the root of a new process group.
+func procGroupSetup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// procGroupTerminate kills the process tree rooted at the command. Windows
+// lacks an equivalent of SIGTERM for console processes in another group, so
+// we kill the tree immediately and ignore the grace period, thus returning
+// a function that does nothing as the cancellation function.
+func procGroupTerminate(cmd *exec.Cmd, grace time.Duration) (func(), error) {
+	pid := strconv.Itoa(cmd.Process.Pid)
+	return func() {}, exec.Command("taskkill", "/T", "/F", "/PID", pid).Run()
+}
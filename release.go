@@ -0,0 +1,134 @@
+// release.go - Releases tagging several repositories with the same version.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// release records the commits we tagged when cutting a release. We store each
+// release inside `.multirepo/releases/<version>.json`, which is tracked along
+// with the configuration when the `.multirepo` directory is tracked using git.
+type release struct {
+	// Version is the version, which is also the tag name.
+	Version string `json:"version"`
+
+	// Created is when we cut the release.
+	Created time.Time `json:"created"`
+
+	// Message is the message of the annotated tags.
+	Message string `json:"message"`
+
+	// Signed indicates whether we signed the tags.
+	Signed bool `json:"signed"`
+
+	// Repos maps the name of each tagged repository to the tagged commit.
+	Repos map[string]string `json:"repos"`
+}
+
+// releasesDirPath returns the path to the directory containing the releases.
+func (dd dotDir) releasesDirPath() string {
+	return filepath.Join(dd.String(), "releases")
+}
+
+// releaseFilePath returns the path to the file recording the given release.
+func (dd dotDir) releaseFilePath(version string) string {
+	return filepath.Join(dd.releasesDirPath(), version+".json")
+}
+
+// validateReleaseVersion returns an error if the version is not usable both
+// as a tag name and as a file name (e.g., because it contains slashes).
+func validateReleaseVersion(version string) error {
+	if version == "" || strings.HasPrefix(version, ".") || strings.HasPrefix(version, "-") ||
+		strings.ContainsAny(version, "/\\ ~^:?*[") || strings.Contains(version, "..") ||
+		strings.HasSuffix(version, ".lock") {
+		return fmt.Errorf("invalid release version: %q", version)
+	}
+	return nil
+}
+
+// readRelease reads the given release.
+func readRelease(env environ, dd dotDir, version string) (*release, error) {
+	filename := dd.releaseFilePath(version)
+	data, err := env.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no such release: %s (hint: use `multirepo release ls` to list the releases)", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rel release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &rel, nil
+}
+
+// writeRelease writes the given release.
+func writeRelease(env environ, dd dotDir, rel *release) error {
+	if err := env.MkdirAll(dd.releasesDirPath(), 0755); err != nil {
+		return err
+	}
+	filename := dd.releaseFilePath(rel.Version)
+	data := append(mustMarshalIndentJSON(rel, "", "  "), '\n')
+	return env.WriteFile(filename, data, 0644)
+}
+
+// listReleases returns the releases sorted by creation time, newest first.
+func listReleases(env environ, dd dotDir) ([]*release, error) {
+	entries, err := env.ReadDir(dd.releasesDirPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var releases []*release
+	for _, entry := range entries {
+		version, found := strings.CutSuffix(entry.Name(), ".json")
+		if !found || entry.IsDir() {
+			continue
+		}
+		rel, err := readRelease(env, dd, version)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, rel)
+	}
+	slices.SortStableFunc(releases, func(a, b *release) int {
+		return b.Created.Compare(a.Created)
+	})
+	return releases, nil
+}
+
+// commitRelease commits the file recording the given release caused by the
+// given command line, provided that the dot directory is tracked using git.
+// You MUST only invoke this function when the `.multirepo` directory has
+// been locked.
+func (dd dotDir) commitRelease(ctx context.Context, env environ, argv []string, rel *release) error {
+	// Do nothing unless the dot directory is tracked
+	tracked, err := dd.isTracked(env)
+	if err != nil || !tracked {
+		return err
+	}
+
+	// Stage and commit, forcing the addition such that we record the release
+	// even when the `.gitignore` file does not allowlist the `releases` directory
+	// (e.g., because the user replaced the one written by `init --track`)
+	filename := filepath.Join("releases", rel.Version+".json")
+	if err := dd.runGit(ctx, env, "add", "-f", filename); err != nil {
+		return err
+	}
+	return dd.runGit(ctx, env, "commit", "-q", "-m", shellquote.Join(argv...), "--", filename)
+}
@@ -0,0 +1,59 @@
+// repogit.go - Executing git inside the repositories.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// repoGitCommand creates a git command to execute inside the given repository.
+func (dd dotDir) repoGitCommand(ctx context.Context, env environ, repo string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdin = io.NopCloser(bytes.NewReader(nil))
+	cmd.Stdout = env.Stdout()
+	cmd.Stderr = env.Stderr()
+	cmd.Dir = dd.repoPath(repo)
+	return cmd
+}
+
+// runRepoGit executes a git command inside the given repository.
+func (dd dotDir) runRepoGit(ctx context.Context, env environ, repo string, args ...string) error {
+	cmd := dd.repoGitCommand(ctx, env, repo, args...)
+	return env.RunCommand(cmd)
+}
+
+// queryRepoGit executes a git command that does not modify the given
+// repository, returning its trimmed standard output. Because the command does
+// not modify the repository, it runs also in dry-run mode.
+func (dd dotDir) queryRepoGit(ctx context.Context,
+	env environ, repo string, args ...string) (string, error) {
+	output, err := queryGit(env, dd.repoGitCommand(ctx, env, repo, args...))
+	return strings.TrimSpace(output), err
+}
+
+// probeRepoGit is like [dotDir.queryRepoGit] but discards the standard error, which
+// is useful when failing is an expected answer (e.g., when there is no upstream).
+func (dd dotDir) probeRepoGit(ctx context.Context,
+	env environ, repo string, args ...string) (string, error) {
+	cmd := dd.repoGitCommand(ctx, env, repo, args...)
+	cmd.Stderr = io.Discard
+	output, err := queryGit(env, cmd)
+	return strings.TrimSpace(output), err
+}
+
+// queryGit runs the given git command, created using [dotDir.repoGitCommand]
+// and possibly customized (e.g., to discard the standard error), which MUST
+// NOT modify the repository, and returns its untrimmed standard output.
+func queryGit(env environ, cmd *exec.Cmd) (string, error) {
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	if err := env.RunQueryCommand(cmd); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
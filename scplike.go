@@ -92,6 +92,17 @@ func (epnt *scpLikeEndpoint) Name() string {
 	return values[len(values)-1]
 }
 
+// Owner returns the owner derived from the path (e.g., the user or the
+// organization owning the repository), which is empty if the path has
+// a single component.
+func (epnt *scpLikeEndpoint) Owner() string {
+	path := strings.Trim(epnt.Path, "/")
+	if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		return path[:idx]
+	}
+	return ""
+}
+
 var (
 	// isSchemeRegExp is a regular expression that matches a scheme.
 	isSchemeRegExp = regexp.MustCompile(`^[^:]+://`)
@@ -123,3 +134,33 @@ func scpLikeParse(endpoint string) (*scpLikeEndpoint, bool) {
 
 	return epnt, true
 }
+
+// scpLikeParseURL is like [scpLikeParse] but also parses URLs with a scheme
+// (e.g., `https://github.com/ooni/probe-cli`), which must contain a host.
+func scpLikeParseURL(endpoint string) (*scpLikeEndpoint, bool) {
+	if !isSchemeRegExp.MatchString(endpoint) {
+		return scpLikeParse(endpoint)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Hostname() == "" {
+		return nil, false
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		port = scpLikeDefaultPorts[strings.ToLower(u.Scheme)]
+	}
+	password, _ := u.User.Password()
+
+	epnt := &scpLikeEndpoint{
+		Protocol: u.Scheme,
+		User:     u.User.Username(),
+		Password: password,
+		Host:     u.Hostname(),
+		Port:     port,
+		Path:     strings.TrimPrefix(u.Path, "/"),
+	}
+
+	return epnt, true
+}
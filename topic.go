@@ -0,0 +1,86 @@
+// topic.go - Topic branches spanning several repositories.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// topic is a branch with the same name created in several repositories to
+// implement a change spanning them. We keep track of the topics locally
+// inside `.multirepo/topics.json`, such that we know which repositories
+// are involved in each change and where to merge the topic branches.
+type topic struct {
+	// Created is when we started the topic.
+	Created time.Time `json:"created"`
+
+	// Repos maps the name of each repository involved in the topic to the
+	// base branch, from which we created the topic branch, and where we
+	// merge the topic branch when finishing the topic.
+	Repos map[string]string `json:"repos"`
+}
+
+// topicsFile is the content of `.multirepo/topics.json`.
+type topicsFile struct {
+	// Topics maps the name of each topic to the topic.
+	Topics map[string]*topic `json:"topics"`
+}
+
+// readTopics reads the topics from the given file. A nonexistent file
+// is equivalent to a file not containing any topic.
+func readTopics(env environ, filename string) (*topicsFile, error) {
+	// check whether the file exists
+	exists, err := env.FileExists(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return &topicsFile{Topics: map[string]*topic{}}, nil
+	}
+
+	// read and parse the file
+	data, err := env.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var topics topicsFile
+	if err := json.Unmarshal(data, &topics); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if topics.Topics == nil {
+		topics.Topics = map[string]*topic{}
+	}
+	return &topics, nil
+}
+
+// writeTopics writes the topics to the given file.
+func writeTopics(env environ, filename string, topics *topicsFile) error {
+	data := append(mustMarshalIndentJSON(topics, "", "  "), '\n')
+	return env.WriteFile(filename, data, 0644)
+}
+
+// lookupTopic returns the topic with the given name or an error.
+func (tf *topicsFile) lookupTopic(name string) (*topic, error) {
+	tp, found := tf.Topics[name]
+	if !found {
+		return nil, fmt.Errorf("no such topic: %s (hint: use `multirepo topic status` to list the topics)", name)
+	}
+	return tp, nil
+}
+
+// currentBranch returns the branch checked out by the given repository,
+// or an empty string when the repository is in detached HEAD state.
+func (dd dotDir) currentBranch(ctx context.Context, env environ, repo string) string {
+	branch, _ := dd.queryRepoGit(ctx, env, repo, "symbolic-ref", "--short", "-q", "HEAD")
+	return branch
+}
+
+// branchExists returns whether the given repository contains the given local branch.
+func (dd dotDir) branchExists(ctx context.Context, env environ, repo, branch string) bool {
+	_, err := dd.queryRepoGit(ctx, env, repo, "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
@@ -0,0 +1,136 @@
+// xlog.go - Logging the commands we execute.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bassosimone/clip"
+	"github.com/kballard/go-shellquote"
+)
+
+// xLogger logs the commands we execute as requested by the `-x` flag.
+//
+// The zero value is not ready to use. Construct using [newXLogger].
+type xLogger struct {
+	// Style is the nil-safe lipgloss style to use.
+	Style *nilSafeLipglossStyle
+
+	// XWriter is the writer used to log executed commands.
+	XWriter io.Writer
+}
+
+// newXLogger creates a new [*xLogger].
+func newXLogger(style *nilSafeLipglossStyle, xwriter io.Writer) *xLogger {
+	return &xLogger{Style: style, XWriter: xwriter}
+}
+
+// Logf logs a shell-like command line built using the given format.
+func (xl *xLogger) Logf(format string, v ...any) {
+	mustFprintf(xl.XWriter, "%s\n", xl.Style.Renderf("+ "+format, v...))
+}
+
+// LogCmd logs the given [*exec.Cmd] including its working directory.
+func (xl *xLogger) LogCmd(cmd *exec.Cmd) {
+	xl.Logf("%s", xLogCmdString(cmd))
+}
+
+// xLogCmdString returns the shell-like representation of the given [*exec.Cmd].
+//
+// When executing a shell snippet (e.g., `foreach -c`), we represent the snippet
+// as is, which is more readable than quoting it as an argument of the shell.
+func xLogCmdString(cmd *exec.Cmd) string {
+	command := shellquote.Join(cmd.Args...)
+	if len(cmd.Args) == 3 && cmd.Args[1] == "-c" && strings.HasSuffix(filepath.Base(cmd.Args[0]), "sh") {
+		command = cmd.Args[2]
+	}
+	if cmd.Dir == "" {
+		return command
+	}
+	return "(cd " + shellquote.Join(cmd.Dir) + " && " + command + ")"
+}
+
+// LogWriteFile logs writing the given data into the given file.
+func (xl *xLogger) LogWriteFile(filename string, data []byte) {
+	xl.Logf("%s", xLogWriteFileString(filename, data, ">"))
+}
+
+// xLogWriteFileString returns the shell-like representation of writing the given
+// data into the given file using the given redirection (i.e., `>` or `>>`).
+func xLogWriteFileString(filename string, data []byte, redirect string) string {
+	escaped := strings.ReplaceAll(string(data), "\n", `\n`)
+	return "printf " + shellquote.Join(escaped) + " " + redirect + " " + shellquote.Join(filename)
+}
+
+// xLogEnviron is an [environ] that logs the commands it runs and the file-system
+// mutations it performs, as requested by the `-x` flag. Wrapping it inside a
+// [*dryRunEnviron] causes it to only log the queries, since the latter prints
+// the mutations instead of performing them, thus each operation is printed once.
+//
+// The zero value is not ready to use. Construct using [newXLogEnviron].
+type xLogEnviron struct {
+	environ
+
+	// xl is the logger used to print what we do.
+	xl *xLogger
+}
+
+var _ environ = (*xLogEnviron)(nil)
+
+// newXLogEnviron creates a new [*xLogEnviron] wrapping the given [environ].
+func newXLogEnviron(env environ) *xLogEnviron {
+	return &xLogEnviron{
+		environ: env,
+		xl:      newXLogger(newNilSafeLipglossStyle(), env.Stderr()),
+	}
+}
+
+// AppendFile implements the [environ] interface.
+func (env *xLogEnviron) AppendFile(filename string, data []byte, perm os.FileMode) error {
+	env.xl.Logf("%s", xLogWriteFileString(filename, data, ">>"))
+	return env.environ.AppendFile(filename, data, perm)
+}
+
+// MkdirAll implements the [environ] interface.
+func (env *xLogEnviron) MkdirAll(path string, perm os.FileMode) error {
+	env.xl.Logf("mkdir -p %s", shellquote.Join(path))
+	return env.environ.MkdirAll(path, perm)
+}
+
+// Remove implements the [environ] interface.
+func (env *xLogEnviron) Remove(path string) error {
+	env.xl.Logf("rm -f %s", shellquote.Join(path))
+	return env.environ.Remove(path)
+}
+
+// RunCommand implements the [environ] interface.
+func (env *xLogEnviron) RunCommand(cmd *exec.Cmd) error {
+	env.xl.LogCmd(cmd)
+	return env.environ.RunCommand(cmd)
+}
+
+// RunQueryCommand implements the [environ] interface.
+func (env *xLogEnviron) RunQueryCommand(cmd *exec.Cmd) error {
+	env.xl.LogCmd(cmd)
+	return env.environ.RunQueryCommand(cmd)
+}
+
+// WriteFile implements the [environ] interface.
+func (env *xLogEnviron) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	env.xl.LogWriteFile(filename, data)
+	return env.environ.WriteFile(filename, data, perm)
+}
+
+// honourPrintCommandsFlag replaces the environment with a [*xLogEnviron] if needed.
+// Callers MUST invoke this function before [honourDryRunFlag], such that the
+// [*dryRunEnviron] wraps the [*xLogEnviron] and prints the mutations once.
+func honourPrintCommandsFlag(args *clip.CommandArgs[environ], xflag bool) {
+	if xflag {
+		args.Env = newXLogEnviron(args.Env)
+	}
+}